@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inirafli/go-url-shortener/internal/storage"
+)
+
+const (
+	// defaultAccessesLimit is the page size when no limit is given
+	defaultAccessesLimit = 50
+	// maxAccessesLimit caps the page size of a single request
+	maxAccessesLimit = 500
+	// defaultReferrersLimit and maxReferrersLimit size the top referrers list
+	defaultReferrersLimit = 10
+	maxReferrersLimit     = 100
+	// recordAccessTimeout bounds the background write of a single access
+	recordAccessTimeout = 5 * time.Second
+)
+
+type AccessEntry struct {
+	AccessedAt time.Time `json:"accessed_at"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Referer    string    `json:"referer,omitempty"`
+}
+
+type AccessesResponse struct {
+	ShortID  string        `json:"short_id"`
+	Limit    int           `json:"limit"`
+	Offset   int           `json:"offset"`
+	Accesses []AccessEntry `json:"accesses"`
+}
+
+type ReferrerEntry struct {
+	// Referer is "(direct)" for visits that sent no Referer header
+	Referer string `json:"referer"`
+	Count   int64  `json:"count"`
+}
+
+type ReferrersResponse struct {
+	ShortID   string          `json:"short_id"`
+	Referrers []ReferrerEntry `json:"referrers"`
+}
+
+// recordAccess logs a visit to shortID in the background when detailed analytics or
+// referrer recording are enabled, so the redirect does not wait for the write.
+func (h *Handler) recordAccess(r *http.Request, shortID string) {
+	var access storage.Access
+	switch {
+	case h.cfg.DetailedAnalytics:
+		access = storage.Access{
+			IP:        clientIP(r),
+			UserAgent: r.UserAgent(),
+			Referer:   r.Referer(),
+		}
+	case h.cfg.RecordReferrer:
+		// Referrer-only attribution keeps no data about the visitor
+		access = storage.Access{Referer: r.Referer()}
+	default:
+		return
+	}
+	// Maintenance mode disables every write, redirects keep working unrecorded
+	if h.InMaintenance() {
+		return
+	}
+	if h.debounce != nil && !h.debounce.allow(shortID, clientIP(r), time.Now()) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), recordAccessTimeout)
+		defer cancel()
+		// Failures are logged by storage; analytics never fail a redirect
+		h.storage.RecordAccess(ctx, shortID, access)
+	}()
+}
+
+// parsePagination reads the limit and offset query parameters.
+func parsePagination(r *http.Request, defaultLimit, maxLimit int) (limit, offset int, ok bool) {
+	query := r.URL.Query()
+
+	limit = defaultLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		limit = min(n, maxLimit)
+	}
+
+	if value := query.Get("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		offset = n
+	}
+
+	return limit, offset, true
+}
+
+// Accesses handles GET /stats/{shortID}/accesses?limit=50&offset=0, returning the
+// recorded visits to a link, newest first. Clients sending Accept: text/csv get CSV.
+func (h *Handler) Accesses(w http.ResponseWriter, r *http.Request) {
+	if !h.cfg.DetailedAnalytics {
+		h.writeError(w, r, http.StatusNotFound, "Detailed analytics are disabled")
+		return
+	}
+
+	limit, offset, ok := parsePagination(r, defaultAccessesLimit, maxAccessesLimit)
+	if !ok {
+		h.writeError(w, r, http.StatusBadRequest, "Invalid 'limit' or 'offset'. Must be a positive and a non-negative integer.")
+		return
+	}
+
+	shortID := r.PathValue("shortID")
+	if _, err := h.storage.Load(r.Context(), shortID); err != nil {
+		log.Printf("Error loading URL for shortID '%s': %v", shortID, err)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, "Short URL not found")
+		} else {
+			h.writeStorageError(w, r, "Failed to retrieve URL", err)
+		}
+
+		return
+	}
+
+	accesses, err := h.storage.Accesses(r.Context(), shortID, limit, offset)
+	if err != nil {
+		h.writeStorageError(w, r, "Failed to load accesses", err)
+		return
+	}
+
+	if accepts(r, "text/csv") {
+		h.writeAccessesCSV(w, r, shortID, accesses)
+		return
+	}
+
+	resp := AccessesResponse{
+		ShortID:  shortID,
+		Limit:    limit,
+		Offset:   offset,
+		Accesses: make([]AccessEntry, 0, len(accesses)),
+	}
+	for _, access := range accesses {
+		resp.Accesses = append(resp.Accesses, AccessEntry(access))
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, http.StatusOK, resp)
+}
+
+// writeAccessesCSV sends accesses as a CSV download with a header row, for spreadsheets.
+func (h *Handler) writeAccessesCSV(w http.ResponseWriter, r *http.Request, shortID string, accesses []storage.Access) {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.Write([]string{"accessed_at", "ip", "user_agent", "referer"})
+	for _, access := range accesses {
+		cw.Write([]string{access.AccessedAt.UTC().Format(time.RFC3339), access.IP, csvText(access.UserAgent), csvText(access.Referer)})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("Error encoding accesses of shortID '%s' as CSV: %v", shortID, err)
+		h.writeError(w, r, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": shortID + "-accesses.csv"}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
+
+// csvText neutralizes client-supplied values that spreadsheets would run as formulas.
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// Referrers handles GET /stats/{shortID}/referrers?limit=10, returning the most
+// common referers of a link's visits.
+func (h *Handler) Referrers(w http.ResponseWriter, r *http.Request) {
+	if !h.cfg.DetailedAnalytics && !h.cfg.RecordReferrer {
+		h.writeError(w, r, http.StatusNotFound, "Referrer recording is disabled")
+		return
+	}
+
+	limit, _, ok := parsePagination(r, defaultReferrersLimit, maxReferrersLimit)
+	if !ok {
+		h.writeError(w, r, http.StatusBadRequest, "Invalid 'limit'. Must be a positive integer.")
+		return
+	}
+
+	shortID := r.PathValue("shortID")
+	if _, err := h.storage.Load(r.Context(), shortID); err != nil {
+		log.Printf("Error loading URL for shortID '%s': %v", shortID, err)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, "Short URL not found")
+		} else {
+			h.writeStorageError(w, r, "Failed to retrieve URL", err)
+		}
+
+		return
+	}
+
+	referrers, err := h.storage.TopReferrers(r.Context(), shortID, limit)
+	if err != nil {
+		h.writeStorageError(w, r, "Failed to load referrers", err)
+		return
+	}
+
+	resp := ReferrersResponse{
+		ShortID:   shortID,
+		Referrers: make([]ReferrerEntry, 0, len(referrers)),
+	}
+	for _, referrer := range referrers {
+		entry := ReferrerEntry{Referer: referrer.Referer, Count: referrer.Count}
+		if entry.Referer == "" {
+			entry.Referer = "(direct)"
+		}
+		resp.Referrers = append(resp.Referrers, entry)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, http.StatusOK, resp)
+}
+
+type SourceEntry struct {
+	// Source is "(unknown)" for links created before sources were recorded
+	Source string `json:"source"`
+	Count  int64  `json:"count"`
+}
+
+type SummaryResponse struct {
+	TotalLinks int64 `json:"total_links"`
+	// ActiveLinks leaves out links that have expired but are still stored
+	ActiveLinks int64         `json:"active_links"`
+	BySource    []SourceEntry `json:"by_source"`
+}
+
+// Summary handles GET /api/stats/summary, returning the number of stored links in total,
+// the number that have not expired, and the total per creation source.
+func (h *Handler) Summary(w http.ResponseWriter, r *http.Request) {
+	counts, err := h.storage.CountBySource(r.Context())
+	if err != nil {
+		h.writeStorageError(w, r, "Failed to load summary", err)
+		return
+	}
+
+	resp := SummaryResponse{BySource: make([]SourceEntry, 0, len(counts))}
+	for _, count := range counts {
+		entry := SourceEntry{Source: count.Source, Count: count.Count}
+		if entry.Source == "" {
+			entry.Source = "(unknown)"
+		}
+		resp.TotalLinks += count.Count
+		resp.ActiveLinks += count.Active
+		resp.BySource = append(resp.BySource, entry)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, http.StatusOK, resp)
+}
+
+type CreatedCountResponse struct {
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	Count int64     `json:"count"`
+}
+
+// CreatedCount handles GET /api/stats/created?from=...&to=..., counting the links
+// created from the first RFC 3339 timestamp up to, but excluding, the second.
+func (h *Handler) CreatedCount(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var bounds [2]time.Time
+	for i, name := range []string{"from", "to"} {
+		value := query.Get(name)
+		if value == "" {
+			h.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Missing '%s' query parameter", name))
+			return
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			h.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid '%s'. Must be an RFC 3339 timestamp such as 2024-01-31T00:00:00Z.", name))
+			return
+		}
+		bounds[i] = t
+	}
+	from, to := bounds[0], bounds[1]
+	if !from.Before(to) {
+		h.writeError(w, r, http.StatusBadRequest, "Invalid range. 'from' must be before 'to'.")
+		return
+	}
+
+	count, err := h.storage.CountCreatedBetween(r.Context(), from, to)
+	if err != nil {
+		h.writeStorageError(w, r, "Failed to count links", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, http.StatusOK, CreatedCountResponse{From: from.UTC(), To: to.UTC(), Count: count})
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/inirafli/go-url-shortener/internal/storage"
+)
+
+func TestSummary(t *testing.T) {
+	store := newMemStore()
+	for _, link := range []storage.Link{
+		{ShortID: "a", Source: "api"},
+		{ShortID: "b", Source: "api", ExpiresAt: time.Now().Add(time.Hour)},
+		{ShortID: "c", Source: "api", ExpiresAt: time.Now().Add(-time.Hour)},
+		{ShortID: "d", Source: "form", ExpiresAt: time.Now().Add(-time.Minute)},
+		{ShortID: "e"},
+	} {
+		store.links[link.ShortID] = link
+	}
+
+	w := httptest.NewRecorder()
+	NewHandler(store, Config{}).Summary(w, httptest.NewRequest(http.MethodGet, "/api/stats/summary", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var got SummaryResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid response %s: %v", w.Body, err)
+	}
+	want := SummaryResponse{
+		TotalLinks:  5,
+		ActiveLinks: 3,
+		BySource: []SourceEntry{
+			{Source: "api", Count: 3},
+			{Source: "(unknown)", Count: 1},
+			{Source: "form", Count: 1},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("summary = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/inirafli/go-url-shortener/internal/storage"
+)
+
+const (
+	// maxBatchOperations caps the operations in a single batch request
+	maxBatchOperations = 100
+	// maxBatchBodyBytes is the body size limit of a batch request
+	maxBatchBodyBytes = 64 * 1024
+)
+
+const (
+	batchOpShorten = "shorten"
+	batchOpResolve = "resolve"
+	batchOpDelete  = "delete"
+)
+
+// BatchOperation is one entry of a batch request. Shorten operations take the
+// same fields as POST /shorten; resolve and delete operations take a short_id.
+type BatchOperation struct {
+	Op      string `json:"op"`
+	ShortID string `json:"short_id,omitempty"`
+	ShortenRequest
+}
+
+type BatchResult struct {
+	Op          string `json:"op"`
+	Status      int    `json:"status"`
+	ShortID     string `json:"short_id,omitempty"`
+	ShortURL    string `json:"short_url,omitempty"`
+	LongURL     string `json:"long_url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// BatchOperations handles POST /api/batch, running an array of shorten, resolve and
+// delete operations in order. Each operation succeeds or fails on its own and gets
+// a result, with an HTTP-style status, at the same position in the response.
+func (h *Handler) BatchOperations(w http.ResponseWriter, r *http.Request) {
+	var ops []BatchOperation
+	if !h.decodeRequestLimit(w, r, &ops, maxBatchBodyBytes) {
+		return
+	}
+
+	if len(ops) == 0 {
+		h.writeError(w, r, http.StatusBadRequest, "Request body must contain at least one operation")
+		return
+	}
+	if len(ops) > maxBatchOperations {
+		h.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Too many operations. At most %d can be sent at once.", maxBatchOperations))
+		return
+	}
+
+	resp := BatchResponse{Results: make([]BatchResult, len(ops))}
+	for i, op := range ops {
+		resp.Results[i] = h.runBatchOperation(r, op)
+	}
+
+	// Results reflect the state of the links at the time of the request
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, http.StatusOK, resp)
+}
+
+// runBatchOperation validates and runs a single batch operation.
+func (h *Handler) runBatchOperation(r *http.Request, op BatchOperation) BatchResult {
+	result := BatchResult{Op: op.Op}
+	fail := func(status int, message string) BatchResult {
+		result.Status = status
+		result.Error = message
+		return result
+	}
+
+	switch op.Op {
+	case batchOpShorten:
+		if op.ShortID != "" {
+			return fail(http.StatusBadRequest, fmt.Sprintf("'%s' is not allowed for shorten operations", h.apiFieldName("short_id")))
+		}
+
+		link, status, message := h.createLink(r, op.ShortenRequest, SourceBatch)
+		if status != 0 {
+			return fail(status, message)
+		}
+
+		short := h.shortenResponse(r, link)
+		result.Status = http.StatusCreated
+		result.ShortID = short.ShortID
+		result.ShortURL = short.ShortURL
+		result.LongURL = link.LongURL
+		result.Description = link.Description
+		return result
+	case batchOpResolve, batchOpDelete:
+		if op.ShortID == "" {
+			return fail(http.StatusBadRequest, fmt.Sprintf("Missing '%s' for %s operation", h.apiFieldName("short_id"), op.Op))
+		}
+		if op.ShortenRequest != (ShortenRequest{}) {
+			return fail(http.StatusBadRequest, fmt.Sprintf("Only '%s' is allowed for %s operations", h.apiFieldName("short_id"), op.Op))
+		}
+		result.ShortID = op.ShortID
+	case "":
+		return fail(http.StatusBadRequest, "Missing 'op' in operation")
+	default:
+		return fail(http.StatusBadRequest, fmt.Sprintf("Unknown op %q. Must be one of shorten, resolve or delete.", op.Op))
+	}
+
+	ctx := r.Context()
+
+	if op.Op == batchOpDelete {
+		if err := h.storage.Delete(ctx, op.ShortID); err != nil {
+			log.Printf("Error deleting shortID '%s': %v", op.ShortID, err)
+			if errors.Is(err, storage.ErrNotFound) {
+				return fail(http.StatusNotFound, "Short URL not found")
+			}
+			return fail(http.StatusInternalServerError, h.storageErrorMessage("Failed to delete short URL", err))
+		}
+
+		log.Printf("Deleted short ID '%s'", op.ShortID)
+		result.Status = http.StatusOK
+		return result
+	}
+
+	link, err := h.storage.Load(ctx, op.ShortID)
+	if err != nil {
+		log.Printf("Error loading URL for shortID '%s': %v", op.ShortID, err)
+		switch {
+		case errors.Is(err, storage.ErrLinkExpired):
+			return fail(http.StatusGone, "Short URL has expired")
+		case errors.Is(err, storage.ErrNotFound):
+			return fail(http.StatusNotFound, "Short URL not found")
+		default:
+			return fail(http.StatusInternalServerError, h.storageErrorMessage("Failed to retrieve URL", err))
+		}
+	}
+
+	result.Status = http.StatusOK
+	result.ShortURL = h.shortURLFor(r, link)
+	result.LongURL = link.LongURL
+	result.Description = link.Description
+	return result
+}
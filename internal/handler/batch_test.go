@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBatchDescription(t *testing.T) {
+	h := NewHandler(newMemStore(), Config{})
+	body := `[
+		{"op":"shorten","long_url":"https://example.com/a","description":"launch post"},
+		{"op":"resolve","short_id":"id1"},
+		{"op":"shorten","long_url":"https://example.com/b"},
+		{"op":"resolve","short_id":"id2"}
+	]`
+	w := httptest.NewRecorder()
+	h.BatchOperations(w, httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d (body %s)", w.Code, http.StatusOK, w.Body)
+	}
+
+	var resp BatchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", w.Body, err)
+	}
+	want := []string{"launch post", "launch post", "", ""}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(resp.Results), len(want))
+	}
+	for i, result := range resp.Results {
+		if result.Error != "" || result.Description != want[i] {
+			t.Errorf("result %d = %+v, want description %q", i, result, want[i])
+		}
+	}
+}
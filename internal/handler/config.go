@@ -0,0 +1,61 @@
+package handler
+
+import "net/http"
+
+// ConfigResponse lists the effective, non-sensitive settings of the service.
+// Secrets such as the admin token or database credentials must never be added here.
+type ConfigResponse struct {
+	BaseURL              string   `json:"base_url,omitempty"`
+	DBDriver             string   `json:"db_driver"`
+	IDStrategy           string   `json:"id_strategy"`
+	ShortIDLength        int      `json:"short_id_length"`
+	MinShortIDLength     int      `json:"min_short_id_length"`
+	CharsetSize          int      `json:"charset_size"`
+	ShortIDChecksum      bool     `json:"short_id_checksum"`
+	MaxTotalLinks        int64    `json:"max_total_links"`
+	MaxFillRatio         float64  `json:"max_fill_ratio"`
+	IDCooldownDays       int      `json:"id_cooldown_days"`
+	DedupEnabled         bool     `json:"dedup_enabled"`
+	DedupWindowSeconds   float64  `json:"dedup_window_seconds"`
+	VanityDomains        []string `json:"vanity_domains"`
+	StripParams          []string `json:"strip_params"`
+	AllowGetShorten      bool     `json:"allow_get_shorten"`
+	ExpandLinks          bool     `json:"expand_links"`
+	MaxRedirectDepth     int      `json:"max_redirect_depth"`
+	EnforceCanonicalHost bool     `json:"enforce_canonical_host"`
+	APINaming            string   `json:"api_naming"`
+}
+
+// ServerConfig handles GET /api/config, reporting the effective non-sensitive configuration.
+func (h *Handler) ServerConfig(w http.ResponseWriter, r *http.Request) {
+	maxDepth := h.cfg.MaxRedirectDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxRedirectDepth
+	}
+
+	settings := h.storage.Settings()
+	resp := ConfigResponse{
+		BaseURL:              h.cfg.BaseURL,
+		DBDriver:             settings.Driver,
+		IDStrategy:           settings.IDStrategy,
+		ShortIDLength:        settings.ShortIDLength,
+		MinShortIDLength:     h.cfg.MinShortIDLength,
+		CharsetSize:          settings.CharsetSize,
+		ShortIDChecksum:      settings.Checksum,
+		MaxTotalLinks:        settings.MaxTotalLinks,
+		MaxFillRatio:         settings.MaxFillRatio,
+		IDCooldownDays:       settings.IDCooldownDays,
+		DedupEnabled:         h.dedup != nil,
+		DedupWindowSeconds:   h.cfg.DedupWindow.Seconds(),
+		VanityDomains:        append([]string{}, h.live().VanityDomains...),
+		StripParams:          append([]string{}, h.live().StripParams...),
+		AllowGetShorten:      h.cfg.AllowGetShorten,
+		ExpandLinks:          h.cfg.ExpandLinks,
+		MaxRedirectDepth:     maxDepth,
+		EnforceCanonicalHost: h.cfg.EnforceCanonicalHost,
+		APINaming:            h.cfg.APINaming,
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, http.StatusOK, resp)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// defaultDebounceSize bounds the (short ID, IP) pairs an accessDebouncer remembers
+const defaultDebounceSize = 10000
+
+// accessDebouncer suppresses repeated visits to the same link from the same IP within
+// a window, so a bot hammering a link is recorded once. It is a fixed-size LRU: when
+// full, the least recently seen pair is forgotten first.
+type accessDebouncer struct {
+	window time.Duration
+	size   int
+
+	mu      sync.Mutex
+	order   *list.List // of *debounceEntry, most recently seen first
+	entries map[debounceKey]*list.Element
+}
+
+type debounceKey struct {
+	shortID string
+	ip      string
+}
+
+type debounceEntry struct {
+	key      debounceKey
+	lastSeen time.Time
+}
+
+func newAccessDebouncer(window time.Duration, size int) *accessDebouncer {
+	return &accessDebouncer{
+		window:  window,
+		size:    size,
+		order:   list.New(),
+		entries: make(map[debounceKey]*list.Element),
+	}
+}
+
+// allow reports whether a visit to shortID from ip at now should be recorded, and
+// remembers it. Repeats within the window are rejected and do not extend it.
+func (d *accessDebouncer) allow(shortID, ip string, now time.Time) bool {
+	key := debounceKey{shortID: shortID, ip: ip}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if elem, ok := d.entries[key]; ok {
+		entry := elem.Value.(*debounceEntry)
+		d.order.MoveToFront(elem)
+		if now.Sub(entry.lastSeen) < d.window {
+			return false
+		}
+		entry.lastSeen = now
+		return true
+	}
+
+	d.entries[key] = d.order.PushFront(&debounceEntry{key: key, lastSeen: now})
+	if d.order.Len() > d.size {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.entries, oldest.Value.(*debounceEntry).key)
+	}
+	return true
+}
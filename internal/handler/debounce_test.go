@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccessDebouncer(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	d := newAccessDebouncer(time.Minute, 2)
+
+	steps := []struct {
+		shortID, ip string
+		at          time.Duration
+		want        bool
+	}{
+		{"a", "1.1.1.1", 0, true},
+		{"a", "1.1.1.1", 30 * time.Second, false},
+		{"a", "2.2.2.2", 30 * time.Second, true},
+		{"a", "1.1.1.1", 59 * time.Second, false},
+		// Rejected repeats do not extend the window
+		{"a", "1.1.1.1", 60 * time.Second, true},
+		{"a", "1.1.1.1", 90 * time.Second, false},
+		// A third pair evicts the least recently seen one, 2.2.2.2
+		{"b", "1.1.1.1", 90 * time.Second, true},
+		{"a", "2.2.2.2", 91 * time.Second, true},
+		// Re-adding 2.2.2.2 evicted 1.1.1.1 on a, so it counts again and evicts b
+		{"a", "1.1.1.1", 92 * time.Second, true},
+		{"a", "2.2.2.2", 93 * time.Second, false},
+		{"b", "1.1.1.1", 93 * time.Second, true},
+	}
+	for i, step := range steps {
+		if got := d.allow(step.shortID, step.ip, start.Add(step.at)); got != step.want {
+			t.Errorf("step %d: allow(%q, %q, +%s) = %v, want %v", i, step.shortID, step.ip, step.at, got, step.want)
+		}
+	}
+	if d.order.Len() != 2 || len(d.entries) != 2 {
+		t.Errorf("debouncer holds %d/%d entries, want 2", d.order.Len(), len(d.entries))
+	}
+}
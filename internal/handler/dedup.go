@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// dedupCache remembers recently created links for a short window so that rapid
+// double submissions of the same request resolve to the same short ID.
+type dedupCache struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+}
+
+// dedupEntry is a single submission. done is closed once the first request for
+// the key has finished saving; shortID is empty if that save failed.
+type dedupEntry struct {
+	done    chan struct{}
+	shortID string
+	expires time.Time
+}
+
+func newDedupCache(window time.Duration) *dedupCache {
+	return &dedupCache{
+		window:  window,
+		entries: make(map[string]*dedupEntry),
+	}
+}
+
+// claim returns the entry for key. The caller that created it (first is true) must
+// save the link and call finish; everyone else waits for the result with wait.
+func (c *dedupCache) claim(key string) (entry *dedupEntry, first bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := c.entries[key]; ok {
+		select {
+		case <-entry.done:
+			if now.Before(entry.expires) && entry.shortID != "" {
+				return entry, false
+			}
+		default:
+			// Still being saved by the first request
+			return entry, false
+		}
+	}
+
+	c.prune(now)
+
+	entry = &dedupEntry{done: make(chan struct{})}
+	c.entries[key] = entry
+	return entry, true
+}
+
+// finish records the outcome of the first request for entry and wakes any waiters.
+func (c *dedupCache) finish(entry *dedupEntry, shortID string) {
+	c.mu.Lock()
+	entry.shortID = shortID
+	entry.expires = time.Now().Add(c.window)
+	c.mu.Unlock()
+	close(entry.done)
+}
+
+// wait blocks until the first request for entry has finished and returns its short ID,
+// or "" if that request failed or ctx ended first.
+func (c *dedupCache) wait(ctx context.Context, entry *dedupEntry) string {
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		return ""
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return entry.shortID
+}
+
+// prune drops expired entries. c.mu must be held.
+func (c *dedupCache) prune(now time.Time) {
+	for key, entry := range c.entries {
+		select {
+		case <-entry.done:
+			if !now.Before(entry.expires) || entry.shortID == "" {
+				delete(c.entries, key)
+			}
+		default:
+		}
+	}
+}
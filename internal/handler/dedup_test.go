@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDedupCache(t *testing.T) {
+	c := newDedupCache(time.Minute)
+
+	first, ok := c.claim("a")
+	if !ok {
+		t.Fatal("first claim of a: first = false, want true")
+	}
+	waiter, ok := c.claim("a")
+	if ok || waiter != first {
+		t.Fatal("second claim of a while saving did not return the pending entry")
+	}
+	if _, ok := c.claim("b"); !ok {
+		t.Error("first claim of b: first = false, want true")
+	}
+
+	done := make(chan string)
+	go func() { done <- c.wait(context.Background(), waiter) }()
+	c.finish(first, "abc123")
+	if got := <-done; got != "abc123" {
+		t.Errorf("wait = %q, want %q", got, "abc123")
+	}
+
+	if entry, ok := c.claim("a"); ok || entry.shortID != "abc123" {
+		t.Errorf("claim of a within window = %+v, %v; want the finished entry", entry, ok)
+	}
+}
+
+func TestDedupCacheRetriesFailures(t *testing.T) {
+	c := newDedupCache(time.Minute)
+
+	entry, _ := c.claim("a")
+	c.finish(entry, "")
+	if got := c.wait(context.Background(), entry); got != "" {
+		t.Errorf("wait on failed save = %q, want empty", got)
+	}
+	if _, ok := c.claim("a"); !ok {
+		t.Error("claim after a failed save: first = false, want true")
+	}
+}
+
+func TestDedupCacheExpiry(t *testing.T) {
+	c := newDedupCache(time.Minute)
+
+	entry, _ := c.claim("a")
+	c.finish(entry, "abc123")
+	c.prune(time.Now().Add(2 * time.Minute))
+	if len(c.entries) != 0 {
+		t.Errorf("entries after prune = %d, want 0", len(c.entries))
+	}
+
+	pending, _ := c.claim("b")
+	c.prune(time.Now().Add(2 * time.Minute))
+	if c.entries["b"] != pending {
+		t.Error("prune dropped an entry that is still being saved")
+	}
+}
+
+func TestDedupCacheWaitCanceled(t *testing.T) {
+	c := newDedupCache(time.Minute)
+	entry, _ := c.claim("a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := c.wait(ctx, entry); got != "" {
+		t.Errorf("wait with canceled context = %q, want empty", got)
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/inirafli/go-url-shortener/internal/middleware"
+)
+
+// defaultErrorPage is shown to browsers when a request fails on the server side.
+const defaultErrorPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>Something went wrong</h1>
+<p>We could not complete your request. Please try again in a moment.</p>
+{{if .RequestID}}<p>If the problem persists, quote request ID <code>{{.RequestID}}</code>.</p>
+{{end}}</body>
+</html>
+`
+
+var defaultErrorTemplate = template.Must(ParseErrorPage(defaultErrorPage))
+
+// ErrorPageData is passed to the error page template.
+type ErrorPageData struct {
+	Status    int
+	Title     string
+	Message   string
+	RequestID string
+}
+
+// ParseErrorPage parses an HTML error page template. The template may use the
+// fields of ErrorPageData, such as {{.Status}} and {{.RequestID}}.
+func ParseErrorPage(text string) (*template.Template, error) {
+	return template.New("error").Parse(text)
+}
+
+// writeErrorPage renders the error page for a server error. It reports false, having
+// written nothing, when the template fails, so the caller can fall back to JSON.
+func (h *Handler) writeErrorPage(w http.ResponseWriter, status int, message string) bool {
+	data := ErrorPageData{
+		Status:    status,
+		Title:     http.StatusText(status),
+		Message:   message,
+		RequestID: w.Header().Get(middleware.RequestIDHeader),
+	}
+
+	// Render up front so a broken template can still fall back to JSON
+	var page bytes.Buffer
+	if err := h.errorPage.Execute(&page, data); err != nil {
+		log.Printf("Error rendering error page: %v", err)
+		return false
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(page.Bytes())
+	return true
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/inirafli/go-url-shortener/internal/storage"
+)
+
+const (
+	// expandTimeout bounds the whole redirect chain walk of a single expand request
+	expandTimeout = 10 * time.Second
+	// defaultMaxRedirectDepth is the number of hops followed when no depth is configured
+	defaultMaxRedirectDepth = 10
+)
+
+var errBlockedAddress = errors.New("destination resolves to a non-public address")
+
+var errRedirectChainTooLong = errors.New("redirect chain too long")
+
+type ExpandHop struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+}
+
+type ExpandResponse struct {
+	ShortID  string      `json:"short_id"`
+	FinalURL string      `json:"final_url"`
+	Chain    []ExpandHop `json:"chain"`
+}
+
+// newOutboundClient returns an HTTP client for requests to user-supplied destinations.
+// It refuses to connect to loopback, private and other non-public addresses, checked
+// after DNS resolution, and does not follow redirects on its own.
+func newOutboundClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return errBlockedAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			// No proxy: it would bypass the address checks above
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 5 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// isPublicIP reports whether ip is a globally routable unicast address.
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// followRedirects walks the redirect chain starting at rawURL and returns every hop,
+// ending with the first non-redirect response.
+func (h *Handler) followRedirects(ctx context.Context, rawURL string) ([]ExpandHop, error) {
+	var chain []ExpandHop
+
+	maxDepth := h.cfg.MaxRedirectDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxRedirectDepth
+	}
+
+	current := rawURL
+	for i := 0; i <= maxDepth; i++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, current, nil)
+		if err != nil {
+			return chain, err
+		}
+
+		resp, err := h.client.Do(req)
+		if err != nil {
+			return chain, err
+		}
+		resp.Body.Close()
+
+		chain = append(chain, ExpandHop{URL: current, Status: resp.StatusCode})
+
+		location := resp.Header.Get("Location")
+		if resp.StatusCode < 300 || resp.StatusCode > 399 || location == "" {
+			return chain, nil
+		}
+
+		next, err := req.URL.Parse(location)
+		if err != nil {
+			return chain, fmt.Errorf("invalid redirect location %q: %w", location, err)
+		}
+		if next.Scheme != "http" && next.Scheme != "https" {
+			return chain, fmt.Errorf("redirect to unsupported scheme %q", next.Scheme)
+		}
+		current = next.String()
+	}
+
+	return chain, fmt.Errorf("%w: more than %d redirects", errRedirectChainTooLong, maxDepth)
+}
+
+// ExpandURL handles GET /api/expand/{shortID}, following the destination's redirects
+// and returning the final URL together with the chain of hops.
+func (h *Handler) ExpandURL(w http.ResponseWriter, r *http.Request) {
+	if !h.cfg.ExpandLinks {
+		h.writeError(w, r, http.StatusNotFound, "Link expansion is disabled")
+		return
+	}
+
+	shortID := r.PathValue("shortID")
+
+	link, err := h.storage.Load(r.Context(), shortID)
+	if err != nil {
+		log.Printf("Error loading URL for shortID '%s': %v", shortID, err)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, "Short URL not found")
+		} else {
+			h.writeStorageError(w, r, "Failed to retrieve URL", err)
+		}
+
+		return
+	}
+
+	// Resolution results reflect the destination's current redirects
+	w.Header().Set("Cache-Control", "no-store")
+
+	ctx, cancel := context.WithTimeout(r.Context(), expandTimeout)
+	defer cancel()
+
+	start := stripQueryParams(link.LongURL, h.live().StripParams)
+	chain, err := h.followRedirects(ctx, start)
+	if err != nil {
+		log.Printf("Error expanding shortID '%s': %v", shortID, err)
+
+		var urlErr *url.Error
+		switch {
+		case errors.Is(err, errRedirectChainTooLong):
+			h.writeError(w, r, http.StatusBadGateway, fmt.Sprintf("Redirect chain too long (%d redirects at most)", len(chain)-1))
+		case errors.Is(err, errBlockedAddress):
+			h.writeError(w, r, http.StatusBadGateway, "Destination redirects to a disallowed address")
+		case errors.As(err, &urlErr) && urlErr.Timeout():
+			h.writeError(w, r, http.StatusGatewayTimeout, "Timed out following destination redirects")
+		default:
+			h.writeError(w, r, http.StatusBadGateway, "Failed to follow destination redirects")
+		}
+
+		return
+	}
+
+	resp := ExpandResponse{
+		ShortID:  shortID,
+		FinalURL: chain[len(chain)-1].URL,
+		Chain:    chain,
+	}
+	h.writeJSON(w, r, http.StatusOK, resp)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/inirafli/go-url-shortener/internal/storage"
+)
+
+// resultPath is where browser form submissions are sent after a successful shorten.
+const resultPath = "/shorten/result"
+
+var resultTemplate = template.Must(template.New("result").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Your short link</title>
+</head>
+<body>
+<h1>Your short link</h1>
+<p><a href="{{.ShortURL}}">{{.ShortURL}}</a></p>
+</body>
+</html>
+`))
+
+// isFormSubmission reports whether r carries an HTML form body rather than JSON.
+func isFormSubmission(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+}
+
+// decodeForm reads the shorten request fields from an HTML form body.
+func (h *Handler) decodeForm(w http.ResponseWriter, r *http.Request, req *ShortenRequest) bool {
+	// Same 4KB limit as JSON bodies
+	r.Body = http.MaxBytesReader(w, r.Body, 1024*4)
+	if err := r.ParseMultipartForm(1024 * 4); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			h.writeError(w, r, http.StatusRequestEntityTooLarge, "Request body must not be larger than 4096 bytes")
+		} else {
+			h.writeError(w, r, http.StatusBadRequest, "Request body contains a malformed form")
+		}
+		return false
+	}
+
+	// Repeated fields are ambiguous, so reject them rather than silently picking one
+	for _, field := range []string{"long_url", "domain", "description"} {
+		if len(r.PostForm[field]) > 1 {
+			h.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Form field '%s' must not be repeated", field))
+			return false
+		}
+	}
+
+	req.LongURL = strings.TrimSpace(r.PostFormValue("long_url"))
+	req.Domain = strings.TrimSpace(r.PostFormValue("domain"))
+	req.Description = strings.TrimSpace(r.PostFormValue("description"))
+	if req.LongURL == "" {
+		h.writeError(w, r, http.StatusBadRequest, "Missing 'long_url' form field")
+		return false
+	}
+	return true
+}
+
+// ShortenResult handles GET /shorten/result?id=..., the page browser form submissions
+// are redirected to once their link has been created.
+func (h *Handler) ShortenResult(w http.ResponseWriter, r *http.Request) {
+	shortID := r.URL.Query().Get("id")
+	if shortID == "" {
+		h.writeError(w, r, http.StatusBadRequest, "Missing 'id' query parameter")
+		return
+	}
+
+	link, err := h.storage.Load(r.Context(), shortID)
+	if err != nil {
+		log.Printf("Error loading URL for shortID '%s': %v", shortID, err)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, "Short URL not found")
+		} else {
+			h.writeStorageError(w, r, "Failed to retrieve URL", err)
+		}
+
+		return
+	}
+	if !h.requirePublicHost(w, r) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	// Anyone can request this page for a guessed ID, so it never reveals the destination
+	// or description; the submitter already knows both
+	data := struct{ ShortURL string }{h.shortURLFor(r, link)}
+	if err := resultTemplate.Execute(w, data); err != nil {
+		log.Printf("Error rendering result page: %v", err)
+	}
+}
+
+// resultURL returns the result page location for shortID.
+func resultURL(shortID string) string {
+	return resultPath + "?id=" + url.QueryEscape(shortID)
+}
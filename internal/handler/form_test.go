@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestShortenResultHidesLinkDetails(t *testing.T) {
+	h := NewHandler(newMemStore(), Config{FormResultRedirect: true})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /shorten", h.ShortenURL)
+	mux.HandleFunc("GET /shorten/result", h.ShortenResult)
+
+	form := url.Values{"long_url": {"https://example.com/private-destination"}, "description": {"private notes"}}
+	r := httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("form POST status = %d, want %d (body %s)", w.Code, http.StatusSeeOther, w.Body)
+	}
+
+	location := w.Header().Get("Location")
+	if location != resultURL("id1") {
+		t.Fatalf("form POST Location = %q, want %q", location, resultURL("id1"))
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, location, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("result page status = %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "http://example.com/id1") {
+		t.Errorf("result page %s does not show the short URL", body)
+	}
+	for _, secret := range []string{"private-destination", "private notes"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("result page leaks %q: %s", secret, body)
+		}
+	}
+}
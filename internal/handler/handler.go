@@ -1,40 +1,582 @@
 package handler
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/inirafli/go-url-shortener/internal/middleware"
 	"github.com/inirafli/go-url-shortener/internal/storage"
+	"golang.org/x/net/idna"
 )
 
+// Config holds optional handler settings.
+type Config struct {
+	// StripParams lists query parameters removed from destinations before redirecting.
+	// It, VanityDomains, KnownShorteners and RobotsTxt can later be changed with Reload.
+	StripParams []string
+	// AllowGetShorten enables the GET /shorten?url=... convenience endpoint.
+	AllowGetShorten bool
+	// AdminToken is the bearer token required by admin endpoints. Admin endpoints are disabled when empty.
+	AdminToken string
+	// NormalizeShortIDs trims a trailing slash and known file extensions from short IDs before lookup.
+	NormalizeShortIDs bool
+	// VanityDomains lists the branded hosts links may be created under.
+	VanityDomains []string
+	// KnownShorteners lists URL shortener domains whose links may not be shortened again.
+	KnownShorteners []string
+	// ExpandLinks enables GET /api/expand/{shortID}, which makes outbound requests to destinations.
+	ExpandLinks bool
+	// ExposeErrorDetails adds the underlying storage error to 500 responses. Meant for development only.
+	ExposeErrorDetails bool
+	// StoreCreatorMeta records the creating client's IP and user agent with each link.
+	StoreCreatorMeta bool
+	// MinShortIDLength is the shortest ID a request may ask for via the length field.
+	MinShortIDLength int
+	// RobotsTxt is the body served at /robots.txt.
+	RobotsTxt string
+	// MaxRedirectDepth caps the redirects followed when expanding a link. Zero uses the default of 10.
+	MaxRedirectDepth int
+	// APINaming selects the JSON field naming convention: NamingSnakeCase (default) or NamingCamelCase.
+	APINaming string
+	// RequestEncodings lists the Content-Encoding values accepted on request bodies. Only "gzip" is supported.
+	RequestEncodings []string
+	// DedupWindow makes identical /shorten submissions from the same client within this window
+	// return the same short ID. Zero disables deduplication.
+	DedupWindow time.Duration
+	// FormResultRedirect accepts HTML form posts on /shorten and answers them with a 303
+	// to a result page instead of JSON.
+	FormResultRedirect bool
+	// BaseURL is the canonical public URL of the service, such as "https://sho.rt".
+	BaseURL string
+	// EnforceCanonicalHost redirects short link requests arriving on any other host than
+	// BaseURL's (or a vanity domain) to the BaseURL host with a 301.
+	EnforceCanonicalHost bool
+	// ShortURLTemplate formats returned short URLs using the {scheme}, {host} and {id}
+	// placeholders. Empty uses DefaultShortURLTemplate.
+	ShortURLTemplate string
+	// AssumeScheme ("http" or "https") is prepended to submitted URLs that have no scheme.
+	// Empty rejects such URLs.
+	AssumeScheme string
+	// CheckLinks enables POST /api/urls/healthcheck, which makes outbound requests to destinations.
+	CheckLinks bool
+	// DetailedAnalytics records the time, IP, user agent and referer of every redirect.
+	DetailedAnalytics bool
+	// RecordReferrer records only the referer of every redirect, for campaign attribution.
+	// DetailedAnalytics implies it.
+	RecordReferrer bool
+	// DefaultHost is used for short URLs when a request has no Host and BaseURL is unset.
+	DefaultHost string
+	// URLCredentials decides what happens to submitted URLs containing a user name or password:
+	// CredentialsReject (the default when empty) refuses them, CredentialsStrip removes the userinfo.
+	URLCredentials string
+	// PreviewSecret is the HMAC key signing preview tokens. Preview tokens are disabled when empty.
+	PreviewSecret string
+	// PreviewTokenTTL is how long a preview token stays valid. Zero uses the default of 5 minutes.
+	PreviewTokenTTL time.Duration
+	// ErrorPage is the HTML template shown to browsers on server errors, see ParseErrorPage.
+	// Empty uses a built-in page.
+	ErrorPage string
+	// MaintenanceMode starts the service with writes disabled. It can be switched at
+	// runtime through /api/maintenance.
+	MaintenanceMode bool
+	// MaintenanceRetryAfter is sent as Retry-After on writes rejected during maintenance.
+	// Zero uses the default of 5 minutes.
+	MaintenanceRetryAfter time.Duration
+	// AccessDebounceWindow records repeated visits to a link from the same IP within this
+	// window only once. Zero records every visit.
+	AccessDebounceWindow time.Duration
+	// AllowDataURLs accepts data: URLs as destinations, up to MaxDataURLBytes long.
+	AllowDataURLs bool
+	// MaxDataURLBytes caps the length of accepted data: URLs. Zero uses the default of 2048.
+	MaxDataURLBytes int
+	// AllowedPorts lists the explicit ports destinations may use. URLs without a port are
+	// always accepted. Empty uses DefaultAllowedPorts.
+	AllowedPorts []string
+	// ChecksumLegacyLookup looks up IDs that fail the short ID checksum instead of rejecting
+	// them up front, for links issued before checksums were switched on.
+	ChecksumLegacyLookup bool
+}
+
+// maxDescriptionLength caps the characters in a link description.
+const maxDescriptionLength = 280
+
+// knownIDExtensions are suffixes stripped from short IDs when normalization is enabled.
+var knownIDExtensions = []string{".html", ".htm", ".json", ".txt"}
+
 type Handler struct {
-	storage *storage.Storage
+	storage Store
+	cfg     Config
+	client  *http.Client
+	dedup   *dedupCache
+	// debounce suppresses repeated access records, nil when disabled
+	debounce *accessDebouncer
+	baseURL  *url.URL
+	// errorPage renders server errors for browsers
+	errorPage *template.Template
+	// maintenance is set while writes are disabled
+	maintenance atomic.Bool
+	// reloadable holds the settings Reload can change; read them through live
+	reloadable atomic.Pointer[ReloadableConfig]
+	// idPrefix is the path short IDs are served under, derived from ShortURLTemplate
+	idPrefix string
+}
+
+func NewHandler(s Store, cfg Config) *Handler {
+	h := &Handler{
+		storage:   s,
+		cfg:       cfg,
+		client:    newOutboundClient(),
+		idPrefix:  "/",
+		errorPage: defaultErrorTemplate,
+	}
+	if cfg.ShortURLTemplate != "" {
+		if prefix, err := ShortIDPathPrefix(cfg.ShortURLTemplate); err == nil {
+			h.idPrefix = prefix
+		} else {
+			log.Printf("Ignoring invalid short URL template: %v", err)
+			h.cfg.ShortURLTemplate = ""
+		}
+	}
+	if cfg.DedupWindow > 0 {
+		h.dedup = newDedupCache(cfg.DedupWindow)
+	}
+	if cfg.AccessDebounceWindow > 0 {
+		h.debounce = newAccessDebouncer(cfg.AccessDebounceWindow, defaultDebounceSize)
+	}
+	if cfg.BaseURL != "" {
+		if u, err := ParseBaseURL(cfg.BaseURL); err == nil {
+			h.baseURL = u
+		} else {
+			log.Printf("Ignoring invalid base URL: %v", err)
+		}
+	}
+	h.maintenance.Store(cfg.MaintenanceMode)
+	h.reloadable.Store(&ReloadableConfig{
+		StripParams:     cfg.StripParams,
+		VanityDomains:   cfg.VanityDomains,
+		KnownShorteners: cfg.KnownShorteners,
+		RobotsTxt:       cfg.RobotsTxt,
+	})
+	if cfg.ErrorPage != "" {
+		if tmpl, err := ParseErrorPage(cfg.ErrorPage); err == nil {
+			h.errorPage = tmpl
+		} else {
+			log.Printf("Ignoring invalid error page: %v", err)
+		}
+	}
+	return h
+}
+
+// ParseBaseURL parses and validates a BaseURL setting, which must be an absolute
+// http or https URL.
+func ParseBaseURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute http or https URL", rawURL)
+	}
+	return u, nil
 }
 
-func NewHandler(s *storage.Storage) *Handler {
-	return &Handler{
-		storage: s,
+// redirectToCanonicalHost sends requests for a non-canonical host to the BaseURL host
+// and reports whether it did.
+func (h *Handler) redirectToCanonicalHost(w http.ResponseWriter, r *http.Request) bool {
+	if !h.cfg.EnforceCanonicalHost || h.baseURL == nil {
+		return false
+	}
+	if strings.EqualFold(r.Host, h.baseURL.Host) || h.isVanityDomain(requestHost(r)) {
+		return false
+	}
+
+	target := url.URL{
+		Scheme:   h.baseURL.Scheme,
+		Host:     h.baseURL.Host,
+		Path:     r.URL.Path,
+		RawQuery: r.URL.RawQuery,
 	}
+	http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+	return true
 }
 
 type ShortenRequest struct {
-	LongURL string `json:"long_url"`
+	LongURL        string `json:"long_url"`
+	Domain         string `json:"domain,omitempty"`
+	RedirectStatus int    `json:"redirect_status,omitempty"`
+	Length         int    `json:"length,omitempty"`
+	Description    string `json:"description,omitempty"`
+	// ExpiresInSeconds and ExpiresAt (RFC 3339) are alternative ways to set an expiry.
+	ExpiresInSeconds json.Number `json:"expires_in_seconds,omitempty"`
+	ExpiresAt        string      `json:"expires_at,omitempty"`
+	// Redirectable set to false stores a "notes only" link that is never redirected.
+	Redirectable *bool `json:"redirectable,omitempty"`
 }
 
 type ShortenResponse struct {
 	ShortURL string `json:"short_url"`
+	ShortID  string `json:"short_id"`
+	// Path is the request path that resolves the link, such as "/abc123" or "/go/abc123".
+	Path string `json:"path"`
+}
+
+// writeJSON sends v as a JSON response using the configured field naming convention.
+func (h *Handler) writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	var body any = v
+	if h.cfg.APINaming == NamingCamelCase {
+		data, err := json.Marshal(v)
+		if err == nil {
+			data, err = renameKeys(data, snakeToCamel)
+		}
+		if err != nil {
+			log.Printf("Error encoding JSON response: %v", err)
+			h.writeError(w, r, http.StatusInternalServerError, "Failed to encode response")
+			return
+		}
+		body = json.RawMessage(data)
+	}
+
+	// Encode fully before sending the status, so a failure can still become a clean 500
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+		h.writeError(w, r, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Error writing JSON response: %v", err)
+	}
+}
+
+// decodeJSON strictly decodes body into v, accepting keys in the configured naming convention.
+func (h *Handler) decodeJSON(body io.Reader, v any) error {
+	if h.cfg.APINaming == NamingCamelCase {
+		// Rewrite camelCase keys to the snake_case names used by the struct tags
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			if data, err = renameKeys(data, camelToSnake); err != nil {
+				return err
+			}
+		}
+		body = bytes.NewReader(data)
+	}
+
+	decoder := json.NewDecoder(body)
+	// Disallow unknown fields in the JSON request to be stricter
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// writeStorageError reports a failed storage operation as a 500. The sanitized error
+// detail is only included when ExposeErrorDetails is set, i.e. outside production.
+func (h *Handler) writeStorageError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	h.writeError(w, r, http.StatusInternalServerError, h.storageErrorMessage(message, err))
+}
+
+// storageErrorMessage appends the sanitized detail of err to message when ExposeErrorDetails is set.
+func (h *Handler) storageErrorMessage(message string, err error) string {
+	if h.cfg.ExposeErrorDetails && err != nil {
+		message = fmt.Sprintf("%s: %s", message, sanitizeErrorDetail(err.Error()))
+	}
+	return message
+}
+
+// sanitizeErrorDetail strips control characters from an error message and caps its length.
+func sanitizeErrorDetail(detail string) string {
+	const maxDetailLength = 300
+
+	detail = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, detail)
+
+	if len(detail) > maxDetailLength {
+		detail = detail[:maxDetailLength] + "..."
+	}
+	return detail
 }
 
-func writeError(w http.ResponseWriter, status int, message string) {
+// MethodNotAllowed returns a handler that rejects every request with a 405 listing the allowed methods.
+func (h *Handler) MethodNotAllowed(allowed ...string) http.HandlerFunc {
+	allow := strings.Join(allowed, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		h.writeError(w, r, http.StatusMethodNotAllowed, "Invalid request method")
+	}
+}
+
+// writeError sends an error response. Server errors are rendered as an HTML page for
+// browsers and otherwise sent as JSON carrying the request ID, so users can quote it.
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	id := w.Header().Get(middleware.RequestIDHeader)
+	if id != "" && status >= http.StatusInternalServerError {
+		log.Printf("Request %s failed with status %d: %s", id, status, message)
+	}
+	if status >= http.StatusInternalServerError && acceptsHTML(r) && h.writeErrorPage(w, status, message) {
+		return
+	}
+
+	body := map[string]string{"error": message}
+	if id != "" && status >= http.StatusInternalServerError {
+		body[h.apiFieldName("request_id")] = id
+	}
+
+	// A map of strings always encodes, but buffer anyway so the body is written in one piece
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(body)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]string{"error": message})
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Error writing error response: %v", err)
+	}
+}
+
+// ServerError reports a generic 500 for r, for middleware such as Recover.
+func (h *Handler) ServerError(w http.ResponseWriter, r *http.Request) {
+	h.writeError(w, r, http.StatusInternalServerError, "Internal server error")
+}
+
+// notFoundPage is shown to browsers that follow an unknown short link.
+const notFoundPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Link not found</title>
+</head>
+<body>
+<h1>Link not found</h1>
+<p>This short link does not exist or is no longer available.</p>
+</body>
+</html>
+`
+
+// writeLinkNotFound reports an unknown short link as an HTML page to browsers and as JSON otherwise.
+func (h *Handler) writeLinkNotFound(w http.ResponseWriter, r *http.Request) {
+	if !acceptsHTML(r) {
+		h.writeError(w, r, http.StatusNotFound, "Short URL not found")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	io.WriteString(w, notFoundPage)
+}
+
+// acceptsHTML reports whether the Accept header of r lists text/html, as browsers' do.
+func acceptsHTML(r *http.Request) bool {
+	return accepts(r, "text/html")
+}
+
+// accepts reports whether the Accept header of r explicitly lists mediaType.
+func accepts(r *http.Request, mediaType string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		listed, _, _ := strings.Cut(part, ";")
+		if strings.EqualFold(strings.TrimSpace(listed), mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultShortURLTemplate renders short URLs as scheme://host/id.
+const DefaultShortURLTemplate = "{scheme}://{host}/{id}"
+
+// publicHost returns the host short URLs are built on: the request's own, or when a
+// proxy or HTTP/2 client left it empty, the BASE_URL host or DefaultHost.
+func (h *Handler) publicHost(r *http.Request) string {
+	switch {
+	case r.Host != "":
+		return r.Host
+	case h.baseURL != nil:
+		return h.baseURL.Host
+	default:
+		return h.cfg.DefaultHost
+	}
+}
+
+// requirePublicHost reports whether short URLs can be built for r, answering with
+// an error when they cannot.
+func (h *Handler) requirePublicHost(w http.ResponseWriter, r *http.Request) bool {
+	if h.publicHost(r) != "" {
+		return true
+	}
+	log.Printf("Request for %s has no Host and neither BASE_URL nor DEFAULT_HOST is set", r.URL.Path)
+	h.writeError(w, r, http.StatusInternalServerError, "Cannot build short URL: request has no host")
+	return false
+}
+
+// shortenResponse describes link, created or rotated in response to r.
+func (h *Handler) shortenResponse(r *http.Request, link storage.Link) ShortenResponse {
+	return ShortenResponse{
+		ShortURL: h.shortURLFor(r, link),
+		ShortID:  link.ShortID,
+		Path:     h.idPrefix + link.ShortID,
+	}
+}
+
+// ShortIDPathPrefix validates a short URL template and returns the path its short
+// IDs are served under, such as "/go/" for "{scheme}://{host}/go/{id}".
+func ShortIDPathPrefix(tmpl string) (string, error) {
+	_, rest, ok := strings.Cut(tmpl, "://")
+	if !ok {
+		return "", fmt.Errorf("template %q has no scheme", tmpl)
+	}
+	slash := strings.Index(rest, "/")
+	if slash < 0 || !strings.HasSuffix(rest, "{id}") || strings.Count(rest, "{id}") != 1 {
+		return "", fmt.Errorf("template %q must end with a path ending in {id}", tmpl)
+	}
+
+	prefix := strings.TrimSuffix(rest[slash:], "{id}")
+	if !strings.HasSuffix(prefix, "/") || strings.ContainsAny(prefix, "{}?#") {
+		return "", fmt.Errorf("template %q must put {id} in its own path segment", tmpl)
+	}
+	return prefix, nil
+}
+
+// shortURLFor builds the full short URL for link from the configured template,
+// using its vanity domain or the host of r.
+func (h *Handler) shortURLFor(r *http.Request, link storage.Link) string {
+	tmpl := h.cfg.ShortURLTemplate
+	if tmpl == "" {
+		tmpl = DefaultShortURLTemplate
+	}
+
+	// Prefer the configured base URL's scheme, since TLS is often terminated by a proxy
+	scheme := "http"
+	switch {
+	case h.baseURL != nil && h.baseURL.Scheme != "":
+		scheme = h.baseURL.Scheme
+	case r.TLS != nil:
+		scheme = "https"
+	}
+	host := h.publicHost(r)
+	if link.Domain != "" {
+		host = link.Domain
+	}
+	return strings.NewReplacer("{scheme}", scheme, "{host}", host, "{id}", link.ShortID).Replace(tmpl)
+}
+
+// requestHost returns the host of r without any port.
+func requestHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		return r.Host
+	}
+	return host
+}
+
+// clientIP returns the IP address of the client that sent r.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// isVanityDomain reports whether domain is one of the configured vanity domains.
+func (h *Handler) isVanityDomain(domain string) bool {
+	for _, d := range h.live().VanityDomains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeShortID trims a trailing slash and a known file extension from a short ID,
+// so that /abc123/ and /abc123.html resolve like /abc123.
+func normalizeShortID(shortID string) string {
+	shortID = strings.TrimSuffix(shortID, "/")
+	lower := strings.ToLower(shortID)
+	for _, ext := range knownIDExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return shortID[:len(shortID)-len(ext)]
+		}
+	}
+	return shortID
+}
+
+// normalizeURL converts an internationalized host in rawURL to its ASCII (punycode) form,
+// so that http://münchen.de and http://xn--mnchen-3ya.de are stored identically.
+func normalizeURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	hostname := u.Hostname()
+	if hostname == "" || net.ParseIP(hostname) != nil {
+		return rawURL, nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(hostname)
+	if err != nil {
+		return "", err
+	}
+	if ascii == hostname {
+		return rawURL, nil
+	}
+
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(ascii, port)
+	} else {
+		u.Host = ascii
+	}
+	return u.String(), nil
+}
+
+// isKnownShortener reports whether the host of rawURL is, or is a subdomain of, a known shortener domain.
+func (h *Handler) isKnownShortener(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range h.live().KnownShorteners {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// isValidRedirectStatus reports whether status may be stored as a link's redirect status.
+// 307 and 308 preserve the request method, 301 and 302 turn it into a GET.
+func isValidRedirectStatus(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
 }
 
 func isValidURL(urlStr string) bool {
@@ -46,25 +588,99 @@ func isValidURL(urlStr string) bool {
 	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
 }
 
-// Handler for URL shortening requests
-func (h *Handler) ShortenURL(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+// stripQueryParams removes every occurrence of the given query parameters from rawURL.
+// Parameter names are matched case-insensitively and the order of the remaining ones is kept.
+func stripQueryParams(rawURL string, params []string) string {
+	if len(params) == 0 {
+		return rawURL
+	}
 
-	if r.Method != http.MethodPost {
-		writeError(w, http.StatusMethodNotAllowed, "Invalid request method")
-		return
+	// Opaque URLs such as data: URLs carry a payload, not query parameters
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" || u.Opaque != "" {
+		return rawURL
 	}
 
-	var req ShortenRequest
+	var kept []string
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		key, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+
+		stripped := false
+		for _, param := range params {
+			if strings.EqualFold(key, param) {
+				stripped = true
+				break
+			}
+		}
+		if !stripped {
+			kept = append(kept, pair)
+		}
+	}
+
+	u.RawQuery = strings.Join(kept, "&")
+	return u.String()
+}
+
+// requestBody returns the request body decoded according to its Content-Encoding.
+// Decompressed bodies are held to the same maxBodyBytes limit as plain ones, so a
+// small compressed payload cannot expand without bound.
+func (h *Handler) requestBody(w http.ResponseWriter, r *http.Request, maxBodyBytes int64) (io.ReadCloser, bool) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return r.Body, true
+	}
+
+	if !h.isAllowedEncoding(encoding) || encoding != "gzip" {
+		h.writeError(w, r, http.StatusUnsupportedMediaType, fmt.Sprintf("Unsupported Content-Encoding %q", encoding))
+		return nil, false
+	}
+
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			h.writeError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not be larger than %d bytes", maxBodyBytes))
+		} else {
+			h.writeError(w, r, http.StatusBadRequest, "Request body is not valid gzip data")
+		}
+		return nil, false
+	}
+
+	return http.MaxBytesReader(w, gz, maxBodyBytes), true
+}
+
+// isAllowedEncoding reports whether encoding is one of the configured request encodings.
+func (h *Handler) isAllowedEncoding(encoding string) bool {
+	for _, allowed := range h.cfg.RequestEncodings {
+		if strings.EqualFold(allowed, encoding) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeRequest decodes the JSON body of an API request into req.
+// It writes an error response and returns false when the body is invalid.
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request, req any) bool {
 	// 4KB limit for the long URL
-	maxBodyBytes := int64(1024 * 4)
+	return h.decodeRequestLimit(w, r, req, 1024*4)
+}
+
+// decodeRequestLimit is decodeRequest with a caller-chosen body size limit.
+func (h *Handler) decodeRequestLimit(w http.ResponseWriter, r *http.Request, req any, maxBodyBytes int64) bool {
 	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
 	defer r.Body.Close()
 
-	decoder := json.NewDecoder(r.Body)
-	// Disallow unknown fields in the JSON request to be stricter
-	decoder.DisallowUnknownFields()
-	err := decoder.Decode(&req)
+	body, ok := h.requestBody(w, r, maxBodyBytes)
+	if !ok {
+		return false
+	}
+	defer body.Close()
+
+	err := h.decodeJSON(body, req)
 
 	// Request error handling
 	if err != nil {
@@ -75,90 +691,445 @@ func (h *Handler) ShortenURL(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case errors.As(err, &syntaxError):
 			msg := fmt.Sprintf("Request body contains badly-formed JSON (at character %d)", syntaxError.Offset)
-			writeError(w, http.StatusBadRequest, msg)
+			h.writeError(w, r, http.StatusBadRequest, msg)
 		case errors.Is(err, io.ErrUnexpectedEOF):
-			writeError(w, http.StatusBadRequest, "Request body contains badly-formed JSON")
+			h.writeError(w, r, http.StatusBadRequest, "Request body contains badly-formed JSON")
 		case errors.As(err, &unmarshalTypeError):
-			msg := fmt.Sprintf("Request body contains an invalid value for the %q field (at character %d)", unmarshalTypeError.Field, unmarshalTypeError.Offset)
-			writeError(w, http.StatusBadRequest, msg)
+			msg := fmt.Sprintf("Request body contains an invalid value for the %q field (at character %d)", h.apiFieldName(unmarshalTypeError.Field), unmarshalTypeError.Offset)
+			h.writeError(w, r, http.StatusBadRequest, msg)
 		case strings.HasPrefix(err.Error(), "json: unknown field "):
 			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
-			msg := fmt.Sprintf("Request body contains unknown field %s", fieldName)
-			writeError(w, http.StatusBadRequest, msg)
+			msg := fmt.Sprintf("Request body contains unknown field %q", h.apiFieldName(strings.Trim(fieldName, `"`)))
+			h.writeError(w, r, http.StatusBadRequest, msg)
 		case errors.Is(err, io.EOF): // Happens with empty body
-			writeError(w, http.StatusBadRequest, "Request body must not be empty")
+			h.writeError(w, r, http.StatusBadRequest, "Request body must not be empty")
 		case errors.As(err, &maxBytesError):
 			msg := fmt.Sprintf("Request body must not be larger than %d bytes", maxBodyBytes)
-			writeError(w, http.StatusRequestEntityTooLarge, msg)
+			h.writeError(w, r, http.StatusRequestEntityTooLarge, msg)
+		case errors.Is(err, gzip.ErrChecksum), errors.Is(err, gzip.ErrHeader):
+			h.writeError(w, r, http.StatusBadRequest, "Request body is not valid gzip data")
 		default:
 			log.Printf("Error decoding JSON: %v", err)
-			writeError(w, http.StatusInternalServerError, "Could not decode request body")
+			h.writeError(w, r, http.StatusInternalServerError, "Could not decode request body")
 		}
 
-		return
+		return false
 	}
 
-	if req.LongURL == "" {
-		writeError(w, http.StatusBadRequest, "Missing 'long_url' in request body")
-		return
+	return true
+}
+
+// saveDeduplicated saves link, or returns the short ID of the link the same client
+// created from an identical request within the dedup window.
+func (h *Handler) saveDeduplicated(ctx context.Context, r *http.Request, req ShortenRequest, link storage.Link) (string, error) {
+	if h.dedup == nil {
+		return h.storage.Save(ctx, link)
 	}
 
-	if !isValidURL(req.LongURL) {
-		writeError(w, http.StatusBadRequest, "Invalid 'long_url' format. Must be a valid HTTP/HTTPS URL.")
-		return
+	// Keyed on the request rather than link, whose relative expiry differs per request
+	key := strings.Join([]string{
+		clientIP(r), req.LongURL, req.Domain, strconv.Itoa(req.RedirectStatus), strconv.Itoa(req.Length),
+		req.Description, req.ExpiresInSeconds.String(), req.ExpiresAt, strconv.FormatBool(req.Redirectable == nil || *req.Redirectable),
+	}, "\x00")
+	entry, first := h.dedup.claim(key)
+	if !first {
+		if shortID := h.dedup.wait(ctx, entry); shortID != "" {
+			return shortID, nil
+		}
+		// The earlier submission failed; create the link ourselves
+		return h.storage.Save(ctx, link)
+	}
+
+	shortID, err := h.storage.Save(ctx, link)
+	h.dedup.finish(entry, shortID)
+	return shortID, err
+}
+
+// maxExpiresInSeconds caps relative expiries at ten years.
+const maxExpiresInSeconds = 10 * 365 * 24 * 60 * 60
+
+// parseExpiry returns the expiry requested by req, or the zero time for none.
+// A non-empty reason explains why the requested expiry is invalid.
+func (h *Handler) parseExpiry(req ShortenRequest) (time.Time, string) {
+	relative, absolute := h.apiFieldName("expires_in_seconds"), h.apiFieldName("expires_at")
+
+	switch {
+	case req.ExpiresInSeconds != "" && req.ExpiresAt != "":
+		return time.Time{}, fmt.Sprintf("Only one of '%s' and '%s' may be given.", relative, absolute)
+	case req.ExpiresInSeconds != "":
+		// Parsed from the raw number so fractions are rejected instead of truncated
+		seconds, err := strconv.ParseInt(req.ExpiresInSeconds.String(), 10, 64)
+		if err != nil || seconds <= 0 || seconds > maxExpiresInSeconds {
+			return time.Time{}, fmt.Sprintf("Invalid '%s'. Must be a whole number of seconds between 1 and %d (10 years).", relative, maxExpiresInSeconds)
+		}
+		return time.Now().Add(time.Duration(seconds) * time.Second), ""
+	case req.ExpiresAt != "":
+		expiresAt, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return time.Time{}, fmt.Sprintf("Invalid '%s'. Must be an RFC 3339 timestamp such as 2030-01-02T15:04:05Z.", absolute)
+		}
+		if !expiresAt.After(time.Now()) {
+			return time.Time{}, fmt.Sprintf("Invalid '%s'. Must be in the future.", absolute)
+		}
+		return expiresAt, ""
+	default:
+		return time.Time{}, ""
+	}
+}
+
+// createLink validates req and stores it as a new link created through source. On failure
+// it returns a non-zero HTTP status and the client-facing message to report with it.
+func (h *Handler) createLink(r *http.Request, req ShortenRequest, source string) (storage.Link, int, string) {
+	normalized, reason := h.checkLongURL(req.LongURL)
+	if reason != "" {
+		return storage.Link{}, http.StatusBadRequest, reason
+	}
+	req.LongURL = normalized
+
+	req.Domain = strings.ToLower(req.Domain)
+	if req.Domain != "" && !h.isVanityDomain(req.Domain) {
+		return storage.Link{}, http.StatusBadRequest, fmt.Sprintf("Domain %q is not a configured vanity domain", req.Domain)
+	}
+
+	if req.RedirectStatus != 0 && !isValidRedirectStatus(req.RedirectStatus) {
+		return storage.Link{}, http.StatusBadRequest, fmt.Sprintf("Invalid '%s'. Must be one of 301, 302, 307 or 308.", h.apiFieldName("redirect_status"))
+	}
+
+	if req.Length != 0 && h.storage.Settings().IDStrategy == storage.IDStrategyWords {
+		return storage.Link{}, http.StatusBadRequest, fmt.Sprintf("Invalid '%s'. Word aliases have no configurable length.", h.apiFieldName("length"))
+	}
+
+	// Premium IDs may be shorter than the default, down to the configured minimum
+	if req.Length != 0 && (req.Length < h.cfg.MinShortIDLength || req.Length > storage.DefaultShortIDLength) {
+		msg := fmt.Sprintf("Invalid '%s'. Must be between %d and %d.", h.apiFieldName("length"), h.cfg.MinShortIDLength, storage.DefaultShortIDLength)
+		return storage.Link{}, http.StatusBadRequest, msg
+	}
+
+	if utf8.RuneCountInString(req.Description) > maxDescriptionLength {
+		return storage.Link{}, http.StatusBadRequest, fmt.Sprintf("Invalid '%s'. Must be at most %d characters.", h.apiFieldName("description"), maxDescriptionLength)
+	}
+
+	expiresAt, reason := h.parseExpiry(req)
+	if reason != "" {
+		return storage.Link{}, http.StatusBadRequest, reason
 	}
 
-	shortID, err := h.storage.Save(ctx, req.LongURL)
+	// Fail before creating a link whose short URL could not be returned
+	if h.publicHost(r) == "" {
+		log.Printf("Request for %s has no Host and neither BASE_URL nor DEFAULT_HOST is set", r.URL.Path)
+		return storage.Link{}, http.StatusInternalServerError, "Cannot build short URL: request has no host"
+	}
+
+	link := storage.Link{
+		LongURL:        req.LongURL,
+		Domain:         req.Domain,
+		RedirectStatus: req.RedirectStatus,
+		IDLength:       req.Length,
+		Description:    req.Description,
+		ExpiresAt:      expiresAt,
+		NotesOnly:      req.Redirectable != nil && !*req.Redirectable,
+		Source:         source,
+	}
+	if h.cfg.StoreCreatorMeta {
+		link.CreatorIP = clientIP(r)
+		link.CreatorUA = r.UserAgent()
+	}
+	shortID, err := h.saveDeduplicated(r.Context(), r, req, link)
 	if err != nil {
 		log.Printf("Error saving URL to storage: %v", err)
-		writeError(w, http.StatusInternalServerError, "Failed to shorten URL")
+
+		switch {
+		case errors.Is(err, storage.ErrLinkLimitReached):
+			return storage.Link{}, http.StatusInsufficientStorage, "Maximum number of links reached"
+		case errors.Is(err, storage.ErrShortIDExhausted) && req.Length != 0:
+			return storage.Link{}, http.StatusConflict, fmt.Sprintf("No unused short ID of length %d is available", req.Length)
+		default:
+			return storage.Link{}, http.StatusInternalServerError, h.storageErrorMessage("Failed to shorten URL", err)
+		}
+	}
+
+	link.ShortID = shortID
+	return link, 0, ""
+}
+
+// Handler for URL shortening requests
+func (h *Handler) ShortenURL(w http.ResponseWriter, r *http.Request) {
+	var req ShortenRequest
+	fromForm := false
+	switch {
+	case r.Method == http.MethodHead:
+		// The router keeps HEAD off /shorten; guard anyway, as HEAD must never create a link
+		h.MethodNotAllowed(http.MethodGet, http.MethodPost)(w, r)
 		return
+	case r.Method == http.MethodGet:
+		// Convenience form: GET /shorten?url=..., only routed when AllowGetShorten is set
+		req.LongURL = strings.TrimSpace(r.URL.Query().Get("url"))
+		if req.LongURL == "" {
+			h.writeError(w, r, http.StatusBadRequest, "Missing 'url' query parameter")
+			return
+		}
+		// Creation responses must never be served from a cache
+		w.Header().Set("Cache-Control", "no-store")
+	case h.cfg.FormResultRedirect && isFormSubmission(r):
+		if !h.decodeForm(w, r, &req) {
+			return
+		}
+		fromForm = true
+	default:
+		if !h.decodeRequest(w, r, &req) {
+			return
+		}
 	}
 
-	// Constructing shortUr;
-	scheme := "http"
-	fullShortURL := fmt.Sprintf("%s://%s/%s", scheme, r.Host, shortID)
+	source := linkSource(r)
+	if fromForm {
+		source = SourceForm
+	}
+	link, status, message := h.createLink(r, req, source)
+	if status != 0 {
+		h.writeError(w, r, status, message)
+		return
+	}
 
-	// Prepare and Send JSON Response
-	resp := ShortenResponse{ShortURL: fullShortURL}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		log.Printf("Error encoding JSON response: %v", err)
+	// Browsers get the post/redirect/get pattern so a reload does not resubmit the form
+	if fromForm {
+		http.Redirect(w, r, resultURL(link.ShortID), http.StatusSeeOther)
+		return
 	}
+
+	// Prepare and Send JSON Response
+	resp := h.shortenResponse(r, link)
+	// Point RESTful clients at the created resource
+	w.Header().Set("Location", resp.ShortURL)
+	// RFC 8288 form of the same, for clients that read links from headers
+	w.Header().Set("Link", "<"+resp.ShortURL+">; rel=\"canonical\"")
+	h.writeJSON(w, r, http.StatusCreated, resp)
 }
 
 // RedirectURL handles requests to redirect a short URL to its original long URL
 func (h *Handler) RedirectURL(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "Invalid request method")
+	if h.redirectToCanonicalHost(w, r) {
 		return
 	}
 
-	shortID := strings.TrimPrefix(r.URL.Path, "/")
+	shortID := r.PathValue("shortID")
+	if h.cfg.NormalizeShortIDs {
+		shortID = normalizeShortID(shortID)
+	}
 	if shortID == "" {
-		writeError(w, http.StatusBadRequest, "Missing short ID in URL path")
+		h.writeError(w, r, http.StatusBadRequest, "Missing short ID in URL path")
+		return
+	}
+
+	// Catch mistyped IDs without a database round trip. With legacy lookups, IDs issued
+	// before checksums were switched on still resolve, and only a miss is reported as mistyped.
+	validChecksum := h.storage.HasValidChecksum(shortID)
+	if !validChecksum && !h.cfg.ChecksumLegacyLookup {
+		h.writeError(w, r, http.StatusBadRequest, "Malformed short ID")
 		return
 	}
 
 	//  Use Storage to Load Long URL
-	longURL, err := h.storage.Load(ctx, shortID)
+	link, err := h.storage.Load(ctx, shortID)
 	if err != nil {
 		log.Printf("Error loading URL for shortID '%s': %v", shortID, err)
 
 		// Check if the error indicates "not found"
-		if strings.Contains(err.Error(), "not found") {
-			writeError(w, http.StatusNotFound, "Short URL not found")
+		if errors.Is(err, storage.ErrNotFound) && !validChecksum {
+			h.writeError(w, r, http.StatusBadRequest, "Malformed short ID")
+		} else if errors.Is(err, storage.ErrLinkExpired) {
+			h.writeError(w, r, http.StatusGone, "Short URL has expired")
+		} else if errors.Is(err, storage.ErrNotFound) {
+			h.writeLinkNotFound(w, r)
 		} else {
 			// Some other unexpected storage error occurred
-			writeError(w, http.StatusInternalServerError, "Failed to retrieve URL")
+			h.writeStorageError(w, r, "Failed to retrieve URL", err)
 		}
 
 		return
 	}
 
+	// Links created under a vanity domain only resolve on that host
+	if link.Domain != "" && !strings.EqualFold(link.Domain, requestHost(r)) {
+		h.writeLinkNotFound(w, r)
+		return
+	}
+
+	// Notes-only links are kept for reference and deliberately never redirect
+	if link.NotesOnly {
+		h.writeError(w, r, http.StatusForbidden, "Short URL is not redirectable")
+		return
+	}
+
+	status := link.RedirectStatus
+	if status == 0 {
+		status = http.StatusFound
+	}
+
+	// Only method-preserving redirects make sense for requests other than GET
+	if r.Method != http.MethodGet && r.Method != http.MethodHead &&
+		status != http.StatusTemporaryRedirect && status != http.StatusPermanentRedirect {
+		h.MethodNotAllowed(http.MethodGet, http.MethodHead)(w, r)
+		return
+	}
+
+	h.recordAccess(r, shortID)
+
+	// Remove unwanted tracking parameters from the destination
+	longURL := stripQueryParams(link.LongURL, h.live().StripParams)
+
 	// Perform HTTP Redirect
-	http.Redirect(w, r, longURL, http.StatusFound)
+	http.Redirect(w, r, longURL, status)
+}
+
+// RequireAdmin wraps next so it only runs for requests carrying the configured admin bearer token.
+func (h *Handler) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.cfg.AdminToken == "" {
+			h.writeError(w, r, http.StatusForbidden, "Admin API is disabled")
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			h.writeError(w, r, http.StatusUnauthorized, "Missing or invalid admin token")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// RotateURL handles POST /api/urls/{shortID}/rotate, giving a link a new short ID
+func (h *Handler) RotateURL(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if !h.requirePublicHost(w, r) {
+		return
+	}
+
+	shortID := r.PathValue("shortID")
+
+	link, err := h.storage.Rotate(ctx, shortID)
+	if err != nil {
+		log.Printf("Error rotating shortID '%s': %v", shortID, err)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, "Short URL not found")
+		} else {
+			h.writeStorageError(w, r, "Failed to rotate short URL", err)
+		}
+
+		return
+	}
+
+	log.Printf("Rotated short ID '%s' to '%s'", shortID, link.ShortID)
+
+	resp := h.shortenResponse(r, link)
+	h.writeJSON(w, r, http.StatusOK, resp)
+}
+
+type PoolStatsResponse struct {
+	OpenConnections int       `json:"open_connections"`
+	InUse           int       `json:"in_use"`
+	Idle            int       `json:"idle"`
+	WaitCount       int64     `json:"wait_count"`
+	WaitDurationMS  int64     `json:"wait_duration_ms"`
+	TakenAt         time.Time `json:"taken_at"`
+}
+
+type HealthResponse struct {
+	Status      string            `json:"status"`
+	Maintenance bool              `json:"maintenance,omitempty"`
+	DBPool      PoolStatsResponse `json:"db_pool"`
+}
+
+// Health handles GET /healthz, reporting liveness and the latest database pool snapshot
+func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	// Pool statistics go stale immediately, never let intermediaries cache them
+	w.Header().Set("Cache-Control", "no-store")
+
+	stats := h.storage.LastPoolStats()
+	resp := HealthResponse{
+		Status:      "ok",
+		Maintenance: h.InMaintenance(),
+		DBPool: PoolStatsResponse{
+			OpenConnections: stats.OpenConnections,
+			InUse:           stats.InUse,
+			Idle:            stats.Idle,
+			WaitCount:       stats.WaitCount,
+			WaitDurationMS:  stats.WaitDuration.Milliseconds(),
+			TakenAt:         stats.TakenAt,
+		},
+	}
+	h.writeJSON(w, r, http.StatusOK, resp)
+}
+
+// RobotsTxt handles GET /robots.txt so crawlers don't follow (and count) short links
+func (h *Handler) RobotsTxt(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, h.live().RobotsTxt)
+}
+
+type DeleteResponse struct {
+	Deleted int64 `json:"deleted"`
+}
+
+// maxAge bounds the ages accepted by parseAge, well below where a Duration overflows
+const maxAge = 100 * 365 * 24 * time.Hour
+
+// parseAge parses an age such as "30d" or "12h". Days are supported on top of time.ParseDuration units.
+// Ages beyond maxAge are rejected, so a huge day count cannot wrap around to a short age.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseInt(days, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid number of days %q", days)
+		}
+		if n > int64(maxAge/(24*time.Hour)) {
+			return 0, fmt.Errorf("age %q exceeds the maximum of %d days", s, maxAge/(24*time.Hour))
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	age, err := time.ParseDuration(s)
+	if err == nil && age > maxAge {
+		return 0, fmt.Errorf("age %q exceeds the maximum of %s", s, maxAge)
+	}
+	return age, err
+}
+
+// DeleteURLs handles DELETE /api/urls?older_than=30d, removing links in bulk.
+// An explicit filter is required so a bare DELETE can never wipe every link.
+func (h *Handler) DeleteURLs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Has("tag") {
+		h.writeError(w, r, http.StatusBadRequest, "Filtering by tag is not supported: links have no tags")
+		return
+	}
+
+	olderThan := query.Get("older_than")
+	if olderThan == "" {
+		h.writeError(w, r, http.StatusBadRequest, "Missing filter: 'older_than' is required")
+		return
+	}
+
+	age, err := parseAge(olderThan)
+	if err != nil || age <= 0 {
+		h.writeError(w, r, http.StatusBadRequest, "Invalid 'older_than'. Use a positive duration such as 30d or 12h.")
+		return
+	}
+
+	deleted, err := h.storage.DeleteByAge(r.Context(), age)
+	if err != nil {
+		log.Printf("Error deleting links older than %s: %v", age, err)
+		h.writeStorageError(w, r, "Failed to delete links", err)
+		return
+	}
+
+	log.Printf("Deleted %d links older than %s", deleted, age)
+	h.writeJSON(w, r, http.StatusOK, DeleteResponse{Deleted: deleted})
 }
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/inirafli/go-url-shortener/internal/storage"
+)
+
+const (
+	// maxHealthCheckLinks caps the links checked by a single request
+	maxHealthCheckLinks = 50
+	// healthCheckConcurrency bounds the outbound requests in flight at once
+	healthCheckConcurrency = 5
+	// healthCheckTimeout bounds each destination check
+	healthCheckTimeout = 5 * time.Second
+)
+
+type HealthCheckRequest struct {
+	ShortIDs []string `json:"short_ids"`
+}
+
+type HealthCheckResult struct {
+	ShortID string `json:"short_id"`
+	LongURL string `json:"long_url,omitempty"`
+	Status  int    `json:"status,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type HealthCheckResponse struct {
+	Results []HealthCheckResult `json:"results"`
+}
+
+// CheckLinks handles POST /api/urls/healthcheck, requesting the destination of each
+// given link and reporting the status code it answers with.
+func (h *Handler) CheckLinks(w http.ResponseWriter, r *http.Request) {
+	if !h.cfg.CheckLinks {
+		h.writeError(w, r, http.StatusNotFound, "Link health checks are disabled")
+		return
+	}
+
+	var req HealthCheckRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	field := h.apiFieldName("short_ids")
+	if len(req.ShortIDs) == 0 {
+		h.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Missing '%s' in request body", field))
+		return
+	}
+	if len(req.ShortIDs) > maxHealthCheckLinks {
+		h.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Too many '%s'. At most %d links can be checked at once.", field, maxHealthCheckLinks))
+		return
+	}
+
+	results := make([]HealthCheckResult, len(req.ShortIDs))
+	sem := make(chan struct{}, healthCheckConcurrency)
+	var wg sync.WaitGroup
+	for i, shortID := range req.ShortIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = h.checkLink(r.Context(), shortID)
+		}()
+	}
+	wg.Wait()
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, http.StatusOK, HealthCheckResponse{Results: results})
+}
+
+// checkLink loads shortID and requests its destination through the SSRF-safe client.
+func (h *Handler) checkLink(ctx context.Context, shortID string) HealthCheckResult {
+	result := HealthCheckResult{ShortID: shortID}
+
+	link, err := h.storage.Load(ctx, shortID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			result.Error = "Short URL not found"
+		} else {
+			result.Error = "Failed to retrieve URL"
+		}
+		return result
+	}
+	result.LongURL = link.LongURL
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	status, err := h.destinationStatus(ctx, http.MethodHead, link.LongURL)
+	// Some servers do not implement HEAD; ask again with GET
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = h.destinationStatus(ctx, http.MethodGet, link.LongURL)
+	}
+
+	switch {
+	case errors.Is(err, errBlockedAddress):
+		result.Error = "Destination resolves to a disallowed address"
+	case errors.Is(err, context.DeadlineExceeded):
+		result.Error = "Timed out"
+	case err != nil:
+		result.Error = "Request failed"
+	default:
+		result.Status = status
+	}
+	return result
+}
+
+// destinationStatus requests rawURL with method and returns the response status.
+// Redirects are reported as they are rather than followed.
+func (h *Handler) destinationStatus(ctx context.Context, method, rawURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
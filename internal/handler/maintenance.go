@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultMaintenanceRetryAfter is the Retry-After sent when none is configured
+const defaultMaintenanceRetryAfter = 5 * time.Minute
+
+type MaintenanceRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type MaintenanceResponse struct {
+	Enabled bool `json:"enabled"`
+}
+
+// InMaintenance reports whether writes are currently disabled.
+func (h *Handler) InMaintenance() bool {
+	return h.maintenance.Load()
+}
+
+// RequireWritable rejects requests with a 503 and Retry-After while maintenance mode is
+// on, so operators can run migrations without links being created or changed.
+func (h *Handler) RequireWritable(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.InMaintenance() {
+			retryAfter := h.cfg.MaintenanceRetryAfter
+			if retryAfter <= 0 {
+				retryAfter = defaultMaintenanceRetryAfter
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+			h.writeError(w, r, http.StatusServiceUnavailable, "Service is in maintenance mode, writes are temporarily disabled")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// Maintenance handles GET and PUT /api/maintenance, reporting or switching maintenance mode.
+func (h *Handler) Maintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		var req MaintenanceRequest
+		if !h.decodeRequest(w, r, &req) {
+			return
+		}
+		if req.Enabled == nil {
+			h.writeError(w, r, http.StatusBadRequest, "Missing 'enabled' in request body")
+			return
+		}
+
+		if previous := h.maintenance.Swap(*req.Enabled); previous != *req.Enabled && *req.Enabled {
+			log.Println("Maintenance mode switched on, writes are disabled")
+		} else if previous != *req.Enabled {
+			log.Println("Maintenance mode switched off, writes are enabled")
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, http.StatusOK, MaintenanceResponse{Enabled: h.InMaintenance()})
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// Supported JSON field naming conventions for API requests and responses.
+const (
+	NamingSnakeCase = "snake_case"
+	NamingCamelCase = "camelCase"
+)
+
+// snakeToCamel converts a snake_case name such as "long_url" to "longUrl".
+func snakeToCamel(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// camelToSnake converts a camelCase name such as "longUrl" to "long_url".
+func camelToSnake(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		if unicode.IsUpper(c) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// apiFieldName returns the snake_case field name as clients see it under the configured naming convention.
+func (h *Handler) apiFieldName(name string) string {
+	if h.cfg.APINaming == NamingCamelCase {
+		return snakeToCamel(name)
+	}
+	return name
+}
+
+// renameKeys rewrites every object key in the JSON document data using rename.
+func renameKeys(data []byte, rename func(string) string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(renameValueKeys(v, rename))
+}
+
+func renameValueKeys(v any, rename func(string) string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		renamed := make(map[string]any, len(v))
+		for key, value := range v {
+			renamed[rename(key)] = renameValueKeys(value, rename)
+		}
+		return renamed
+	case []any:
+		for i := range v {
+			v[i] = renameValueKeys(v[i], rename)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inirafli/go-url-shortener/internal/storage"
+)
+
+// defaultPreviewTokenTTL is how long preview tokens stay valid when no TTL is configured
+const defaultPreviewTokenTTL = 5 * time.Minute
+
+var errInvalidPreviewToken = errors.New("invalid preview token")
+
+var errPreviewTokenExpired = errors.New("preview token expired")
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Link preview</title>
+</head>
+<body>
+<h1>Link preview</h1>
+<p>This short link redirects to:</p>
+<p><code>{{.LongURL}}</code></p>
+{{if .Description}}<p>{{.Description}}</p>
+{{end}}</body>
+</html>
+`))
+
+type PreviewTokenResponse struct {
+	Token      string    `json:"token"`
+	PreviewURL string    `json:"preview_url"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type PreviewResponse struct {
+	ShortID     string `json:"short_id"`
+	LongURL     string `json:"long_url"`
+	Description string `json:"description,omitempty"`
+}
+
+// signPreviewToken returns a token granting a preview of shortID until expiresAt.
+// The token is the base64url payload "<expiry>:<shortID>" and its HMAC-SHA256, joined by a dot.
+func (h *Handler) signPreviewToken(shortID string, expiresAt time.Time) string {
+	payload := strconv.FormatInt(expiresAt.Unix(), 10) + ":" + shortID
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(h.previewMAC(encoded))
+}
+
+// verifyPreviewToken checks the signature and expiry of token and returns its short ID.
+func (h *Handler) verifyPreviewToken(token string, now time.Time) (string, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", errInvalidPreviewToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, h.previewMAC(encoded)) {
+		return "", errInvalidPreviewToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errInvalidPreviewToken
+	}
+	expiry, shortID, ok := strings.Cut(string(payload), ":")
+	if !ok || shortID == "" {
+		return "", errInvalidPreviewToken
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", errInvalidPreviewToken
+	}
+	if !now.Before(time.Unix(unix, 0)) {
+		return "", errPreviewTokenExpired
+	}
+	return shortID, nil
+}
+
+func (h *Handler) previewMAC(encoded string) []byte {
+	mac := hmac.New(sha256.New, []byte(h.cfg.PreviewSecret))
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// CreatePreviewToken handles POST /api/preview-token/{shortID}, issuing a signed token
+// that lets its holder see the link's destination at /preview until it expires.
+func (h *Handler) CreatePreviewToken(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.PreviewSecret == "" {
+		h.writeError(w, r, http.StatusNotFound, "Preview tokens are disabled")
+		return
+	}
+
+	shortID := r.PathValue("shortID")
+
+	// Only issue tokens for links that currently resolve
+	if _, err := h.storage.Load(r.Context(), shortID); err != nil {
+		log.Printf("Error loading URL for shortID '%s': %v", shortID, err)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, "Short URL not found")
+		} else {
+			h.writeStorageError(w, r, "Failed to retrieve URL", err)
+		}
+
+		return
+	}
+
+	ttl := h.cfg.PreviewTokenTTL
+	if ttl <= 0 {
+		ttl = defaultPreviewTokenTTL
+	}
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	token := h.signPreviewToken(shortID, expiresAt)
+
+	resp := PreviewTokenResponse{
+		Token:      token,
+		PreviewURL: "/preview?token=" + token,
+		ExpiresAt:  expiresAt.UTC(),
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, http.StatusCreated, resp)
+}
+
+// Preview handles GET /preview?token=..., showing the destination of the link a
+// preview token was issued for without redirecting to it.
+func (h *Handler) Preview(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.PreviewSecret == "" {
+		h.writeError(w, r, http.StatusNotFound, "Preview tokens are disabled")
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		h.writeError(w, r, http.StatusBadRequest, "Missing 'token' query parameter")
+		return
+	}
+
+	shortID, err := h.verifyPreviewToken(token, time.Now())
+	if err != nil {
+		if errors.Is(err, errPreviewTokenExpired) {
+			h.writeError(w, r, http.StatusGone, "Preview token has expired")
+		} else {
+			h.writeError(w, r, http.StatusForbidden, "Invalid preview token")
+		}
+		return
+	}
+
+	link, err := h.storage.Load(r.Context(), shortID)
+	if err != nil {
+		log.Printf("Error loading URL for shortID '%s': %v", shortID, err)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			h.writeLinkNotFound(w, r)
+		} else {
+			h.writeStorageError(w, r, "Failed to retrieve URL", err)
+		}
+
+		return
+	}
+
+	// Tokens are short-lived, so the preview must not outlive them in a cache
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !acceptsHTML(r) {
+		h.writeJSON(w, r, http.StatusOK, PreviewResponse{ShortID: shortID, LongURL: link.LongURL, Description: link.Description})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := previewTemplate.Execute(w, link); err != nil {
+		log.Printf("Error rendering preview page: %v", err)
+	}
+}
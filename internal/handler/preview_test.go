@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyPreviewToken(t *testing.T) {
+	h := NewHandler(nil, Config{PreviewSecret: "secret"})
+	other := NewHandler(nil, Config{PreviewSecret: "other"})
+	now := time.Unix(1700000000, 0)
+	valid := h.signPreviewToken("abc123", now.Add(time.Minute))
+
+	// forged signs payload with h's secret, so only the payload shape is wrong
+	forged := func(payload string) string {
+		encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+		return encoded + "." + base64.RawURLEncoding.EncodeToString(h.previewMAC(encoded))
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr error
+	}{
+		{"valid", valid, "abc123", nil},
+		{"expired", h.signPreviewToken("abc123", now), "", errPreviewTokenExpired},
+		{"other secret", other.signPreviewToken("abc123", now.Add(time.Minute)), "", errInvalidPreviewToken},
+		{"tampered payload", base64.RawURLEncoding.EncodeToString([]byte("9999999999:abc123")) + valid[strings.Index(valid, "."):], "", errInvalidPreviewToken},
+		{"no signature", valid[:strings.Index(valid, ".")], "", errInvalidPreviewToken},
+		{"bad signature encoding", valid + "!", "", errInvalidPreviewToken},
+		{"no short ID", forged("9999999999:"), "", errInvalidPreviewToken},
+		{"no separator", forged("9999999999"), "", errInvalidPreviewToken},
+		{"bad expiry", forged("soon:abc123"), "", errInvalidPreviewToken},
+		{"empty", "", "", errInvalidPreviewToken},
+	}
+	for _, tt := range tests {
+		got, err := h.verifyPreviewToken(tt.token, now)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: verifyPreviewToken = %q, %v; want %q, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package handler
+
+import "log"
+
+// ReloadableConfig holds the handler settings that can be changed while the service
+// runs. Their initial values come from the matching Config fields.
+type ReloadableConfig struct {
+	StripParams     []string
+	VanityDomains   []string
+	KnownShorteners []string
+	RobotsTxt       string
+}
+
+// Reload atomically replaces the reloadable settings. Requests already in flight keep
+// the settings they started with.
+func (h *Handler) Reload(rc ReloadableConfig) {
+	h.reloadable.Store(&rc)
+	log.Printf("Reloaded handler settings: %d stripped params, %d vanity domains, %d known shorteners",
+		len(rc.StripParams), len(rc.VanityDomains), len(rc.KnownShorteners))
+}
+
+// live returns the current reloadable settings.
+func (h *Handler) live() *ReloadableConfig {
+	return h.reloadable.Load()
+}
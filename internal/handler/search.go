@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultSearchLimit is the number of results returned when no limit is given
+	defaultSearchLimit = 20
+	// maxSearchLimit caps the results of a single search request
+	maxSearchLimit = 100
+)
+
+type SearchResult struct {
+	ShortID      string `json:"short_id"`
+	ShortURL     string `json:"short_url"`
+	LongURL      string `json:"long_url"`
+	Description  string `json:"description,omitempty"`
+	Redirectable bool   `json:"redirectable"`
+	CreatorIP    string `json:"creator_ip,omitempty"`
+	CreatorUA    string `json:"creator_ua,omitempty"`
+}
+
+type SearchResponse struct {
+	Results []SearchResult `json:"results"`
+}
+
+// SearchURLs handles GET /api/urls/search?q=..., finding links whose destination
+// contains q. An optional limit is capped at maxSearchLimit.
+func (h *Handler) SearchURLs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		h.writeError(w, r, http.StatusBadRequest, "Missing 'q' query parameter")
+		return
+	}
+
+	limit := defaultSearchLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			h.writeError(w, r, http.StatusBadRequest, "Invalid 'limit'. Must be a positive integer.")
+			return
+		}
+		limit = min(n, maxSearchLimit)
+	}
+
+	links, err := h.storage.Search(r.Context(), q, limit)
+	if err != nil {
+		log.Printf("Error searching links for %q: %v", q, err)
+		h.writeStorageError(w, r, "Failed to search links", err)
+		return
+	}
+
+	if !h.requirePublicHost(w, r) {
+		return
+	}
+
+	resp := SearchResponse{Results: make([]SearchResult, 0, len(links))}
+	for _, link := range links {
+		resp.Results = append(resp.Results, SearchResult{
+			ShortID:      link.ShortID,
+			ShortURL:     h.shortURLFor(r, link),
+			LongURL:      link.LongURL,
+			Description:  link.Description,
+			Redirectable: !link.NotesOnly,
+			CreatorIP:    link.CreatorIP,
+			CreatorUA:    link.CreatorUA,
+		})
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, http.StatusOK, resp)
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// LinkSourceHeader lets clients name how a link is being created, see linkSource.
+const LinkSourceHeader = "X-Link-Source"
+
+// Creation sources recorded with each link.
+const (
+	SourceAPI   = "api"
+	SourceUI    = "ui"
+	SourceCLI   = "cli"
+	SourceForm  = "form"
+	SourceBatch = "batch"
+)
+
+// linkSource returns the creation source of an API request: the LinkSourceHeader value
+// when it names a client (api, ui or cli), otherwise SourceAPI. Form and batch sources
+// are inferred from the endpoint and cannot be claimed through the header.
+func linkSource(r *http.Request) string {
+	switch source := strings.ToLower(strings.TrimSpace(r.Header.Get(LinkSourceHeader))); source {
+	case SourceUI, SourceCLI:
+		return source
+	default:
+		return SourceAPI
+	}
+}
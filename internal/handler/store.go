@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/inirafli/go-url-shortener/internal/storage"
+)
+
+// Store is the link storage the handlers run on. *storage.Storage implements it.
+type Store interface {
+	Save(ctx context.Context, link storage.Link) (string, error)
+	Load(ctx context.Context, shortID string) (storage.Link, error)
+	Rotate(ctx context.Context, shortID string) (storage.Link, error)
+	Delete(ctx context.Context, shortID string) error
+	DeleteByAge(ctx context.Context, olderThan time.Duration) (int64, error)
+	Search(ctx context.Context, query string, limit int) ([]storage.Link, error)
+	HasValidChecksum(shortID string) bool
+	Settings() storage.Settings
+	LastPoolStats() storage.PoolStats
+
+	RecordAccess(ctx context.Context, shortID string, access storage.Access) error
+	Accesses(ctx context.Context, shortID string, limit, offset int) ([]storage.Access, error)
+	TopReferrers(ctx context.Context, shortID string, limit int) ([]storage.ReferrerCount, error)
+	CountBySource(ctx context.Context) ([]storage.SourceCount, error)
+	CountCreatedBetween(ctx context.Context, from, to time.Time) (int64, error)
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/inirafli/go-url-shortener/internal/storage"
+)
+
+// memStore is an in-memory Store. Short IDs are numbered in creation order.
+type memStore struct {
+	mu    sync.Mutex
+	links map[string]storage.Link
+	next  int
+	// checksum validates short IDs; nil accepts every ID
+	checksum func(shortID string) bool
+	// loads counts Load calls
+	loads int
+}
+
+func newMemStore() *memStore {
+	return &memStore{links: make(map[string]storage.Link)}
+}
+
+func (m *memStore) Save(ctx context.Context, link storage.Link) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.next++
+	link.ShortID = fmt.Sprintf("id%d", m.next)
+	m.links[link.ShortID] = link
+	return link.ShortID, nil
+}
+
+func (m *memStore) Load(ctx context.Context, shortID string) (storage.Link, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loads++
+	link, ok := m.links[shortID]
+	if !ok {
+		return storage.Link{}, fmt.Errorf("%w: %s", storage.ErrNotFound, shortID)
+	}
+	if !link.ExpiresAt.IsZero() && !time.Now().Before(link.ExpiresAt) {
+		return storage.Link{}, fmt.Errorf("%w: %w: %s", storage.ErrLinkExpired, storage.ErrNotFound, shortID)
+	}
+	return link, nil
+}
+
+func (m *memStore) Rotate(ctx context.Context, shortID string) (storage.Link, error) {
+	return storage.Link{}, storage.ErrNotFound
+}
+
+func (m *memStore) Delete(ctx context.Context, shortID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.links[shortID]; !ok {
+		return storage.ErrNotFound
+	}
+	delete(m.links, shortID)
+	return nil
+}
+
+func (m *memStore) DeleteByAge(ctx context.Context, olderThan time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (m *memStore) Search(ctx context.Context, query string, limit int) ([]storage.Link, error) {
+	return nil, nil
+}
+
+func (m *memStore) HasValidChecksum(shortID string) bool {
+	return m.checksum == nil || m.checksum(shortID)
+}
+
+func (m *memStore) Settings() storage.Settings {
+	return storage.Settings{IDStrategy: storage.IDStrategyRandom, ShortIDLength: storage.DefaultShortIDLength}
+}
+
+func (m *memStore) LastPoolStats() storage.PoolStats { return storage.PoolStats{} }
+
+func (m *memStore) RecordAccess(ctx context.Context, shortID string, access storage.Access) error {
+	return nil
+}
+
+func (m *memStore) Accesses(ctx context.Context, shortID string, limit, offset int) ([]storage.Access, error) {
+	return nil, nil
+}
+
+func (m *memStore) TopReferrers(ctx context.Context, shortID string, limit int) ([]storage.ReferrerCount, error) {
+	return nil, nil
+}
+
+func (m *memStore) CountBySource(ctx context.Context) ([]storage.SourceCount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bySource := make(map[string]*storage.SourceCount)
+	for _, link := range m.links {
+		count, ok := bySource[link.Source]
+		if !ok {
+			count = &storage.SourceCount{Source: link.Source}
+			bySource[link.Source] = count
+		}
+		count.Count++
+		if link.ExpiresAt.IsZero() || time.Now().Before(link.ExpiresAt) {
+			count.Active++
+		}
+	}
+
+	counts := make([]storage.SourceCount, 0, len(bySource))
+	for _, count := range bySource {
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Source < counts[j].Source
+	})
+	return counts, nil
+}
+
+func (m *memStore) CountCreatedBetween(ctx context.Context, from, to time.Time) (int64, error) {
+	return 0, nil
+}
+
+// newTestServer routes the shorten and redirect endpoints like main does.
+func newTestServer(h *Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /shorten", h.ShortenURL)
+	mux.HandleFunc("/{shortID...}", h.RedirectURL)
+	return mux
+}
+
+func TestShortenRedirectRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		longURL string
+		cfg     Config
+		want    string
+	}{
+		{name: "plain", longURL: "https://example.com/a?b=c", want: "https://example.com/a?b=c"},
+		{name: "assumed scheme", longURL: "example.com/x", cfg: Config{AssumeScheme: "https"}, want: "https://example.com/x"},
+		{name: "stripped params", longURL: "https://example.com/?utm_source=x&id=1", cfg: Config{StripParams: []string{"utm_source"}}, want: "https://example.com/?id=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newTestServer(NewHandler(newMemStore(), tt.cfg))
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(`{"long_url":"`+tt.longURL+`"}`)))
+			if w.Code != http.StatusCreated {
+				t.Fatalf("POST /shorten status = %d, want %d (body %s)", w.Code, http.StatusCreated, w.Body)
+			}
+			var resp ShortenResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response %s: %v", w.Body, err)
+			}
+			if want := "http://example.com/" + resp.ShortID; resp.ShortURL != want || w.Header().Get("Location") != want {
+				t.Errorf("short_url = %q, Location = %q, want %q", resp.ShortURL, w.Header().Get("Location"), want)
+			}
+
+			w = httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+resp.ShortID, nil))
+			if w.Code != http.StatusFound {
+				t.Fatalf("GET /%s status = %d, want %d", resp.ShortID, w.Code, http.StatusFound)
+			}
+			if got := w.Header().Get("Location"); got != tt.want {
+				t.Errorf("GET /%s Location = %q, want %q", resp.ShortID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedirectUnknownID(t *testing.T) {
+	tests := []struct {
+		accept   string
+		wantType string
+	}{
+		{"", "application/json"},
+		{"text/html", "text/html; charset=utf-8"},
+	}
+
+	mux := newTestServer(NewHandler(newMemStore(), Config{}))
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != tt.wantType {
+			t.Errorf("GET /missing with Accept %q = %d %q, want %d %q", tt.accept, w.Code, w.Header().Get("Content-Type"), http.StatusNotFound, tt.wantType)
+		}
+	}
+}
+
+func TestRedirectChecksum(t *testing.T) {
+	tests := []struct {
+		name       string
+		legacy     bool
+		shortID    string
+		wantStatus int
+		wantLoads  int
+	}{
+		{"valid", false, "goodx", http.StatusFound, 1},
+		{"invalid rejected before lookup", false, "legacy", http.StatusBadRequest, 0},
+		{"valid but unknown", false, "missingx", http.StatusNotFound, 1},
+		{"legacy ID resolves", true, "legacy", http.StatusFound, 1},
+		{"legacy lookup miss", true, "typo", http.StatusBadRequest, 1},
+		{"legacy valid", true, "goodx", http.StatusFound, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemStore()
+			// Test IDs carry a valid checksum when they end in "x"
+			store.checksum = func(shortID string) bool { return strings.HasSuffix(shortID, "x") }
+			store.links["goodx"] = storage.Link{ShortID: "goodx", LongURL: "https://example.com/good"}
+			store.links["legacy"] = storage.Link{ShortID: "legacy", LongURL: "https://example.com/legacy"}
+			mux := newTestServer(NewHandler(store, Config{ChecksumLegacyLookup: tt.legacy}))
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+tt.shortID, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("GET /%s status = %d, want %d", tt.shortID, w.Code, tt.wantStatus)
+			}
+			if store.loads != tt.wantLoads {
+				t.Errorf("GET /%s ran %d lookups, want %d", tt.shortID, store.loads, tt.wantLoads)
+			}
+		})
+	}
+}
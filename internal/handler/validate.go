@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// DefaultAllowedPorts are the explicit destination ports accepted when none are configured.
+var DefaultAllowedPorts = []string{"80", "443"}
+
+// defaultMaxDataURLBytes caps data: URLs when no limit is configured
+const defaultMaxDataURLBytes = 2048
+
+// dataURLMediaTypes are the inert media types accepted in data: URLs. An empty type
+// means text/plain, see RFC 2397.
+var dataURLMediaTypes = []string{"", "text/plain", "image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// Values of Config.URLCredentials.
+const (
+	CredentialsReject = "reject"
+	CredentialsStrip  = "strip"
+)
+
+type ValidateRequest struct {
+	LongURL string `json:"long_url"`
+}
+
+type ValidateResponse struct {
+	Valid  bool   `json:"valid"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// checkLongURL trims, normalizes and validates a destination before it is shortened.
+// It returns the normalized URL, or a client-facing reason why it cannot be shortened.
+func (h *Handler) checkLongURL(longURL string) (string, string) {
+	field := h.apiFieldName("long_url")
+
+	// Pasted URLs often carry trailing newlines or zero-width characters; whitespace-only input counts as missing
+	longURL = strings.TrimFunc(longURL, isInvisible)
+	if longURL == "" {
+		return "", fmt.Sprintf("Missing '%s' in request body", field)
+	}
+	if strings.IndexFunc(longURL, isHiddenChar) >= 0 {
+		return "", fmt.Sprintf("Invalid '%s'. It must not contain control or invisible characters.", field)
+	}
+
+	if len(longURL) >= len("data:") && strings.EqualFold(longURL[:len("data:")], "data:") {
+		return h.checkDataURL(longURL)
+	}
+
+	// Bare hosts such as "example.com" get the configured default scheme
+	if h.cfg.AssumeScheme != "" && !hasSchemePrefix(longURL) {
+		longURL = h.cfg.AssumeScheme + "://" + longURL
+	}
+
+	normalized, err := normalizeURL(longURL)
+	if err != nil || !isValidURL(normalized) {
+		return "", h.invalidLongURL()
+	}
+
+	if u, err := url.Parse(normalized); err == nil {
+		// Userinfo such as "user:pass@" would be disclosed to everyone the short link is shared with
+		if u.User != nil {
+			if h.cfg.URLCredentials != CredentialsStrip {
+				return "", fmt.Sprintf("URLs containing credentials cannot be shortened. Remove the user name and password from '%s'.", field)
+			}
+			u.User = nil
+			normalized = u.String()
+		}
+
+		// Keep links away from non-web services such as SSH or databases
+		if port := u.Port(); port != "" && !h.isAllowedPort(port) {
+			return "", fmt.Sprintf("Port %s is not allowed in '%s'", port, field)
+		}
+	}
+
+	// Avoid chains of redirects through other shorteners
+	if h.isKnownShortener(normalized) {
+		return "", "Links from other URL shorteners cannot be shortened. Use the original destination instead."
+	}
+
+	return normalized, ""
+}
+
+// invalidLongURL is the reason given for a destination that is not a valid URL.
+func (h *Handler) invalidLongURL() string {
+	return fmt.Sprintf("Invalid '%s' format. Must be a valid HTTP/HTTPS URL.", h.apiFieldName("long_url"))
+}
+
+// isAllowedPort reports whether an explicit destination port is in AllowedPorts,
+// or is a standard web port when none are configured.
+func (h *Handler) isAllowedPort(port string) bool {
+	allowed := h.cfg.AllowedPorts
+	if len(allowed) == 0 {
+		allowed = DefaultAllowedPorts
+	}
+	return slices.Contains(allowed, port)
+}
+
+// hasSchemePrefix reports whether s starts with a URI scheme followed by "://", so
+// "example.com/?next=https://x" still counts as a bare host. See RFC 3986, section 3.1.
+func hasSchemePrefix(s string) bool {
+	scheme, _, ok := strings.Cut(s, "://")
+	if !ok || scheme == "" {
+		return false
+	}
+	for i, c := range scheme {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isInvisible reports whether r is whitespace, a control character or an invisible
+// formatting character such as a zero-width space.
+func isInvisible(r rune) bool {
+	return unicode.IsSpace(r) || isHiddenChar(r)
+}
+
+// isHiddenChar reports whether r is a control or invisible formatting character.
+func isHiddenChar(r rune) bool {
+	return unicode.IsControl(r) || unicode.Is(unicode.Cf, r)
+}
+
+// checkDataURL validates a data: URL, which is only accepted when AllowDataURLs is set.
+// Even then only the inert media types in dataURLMediaTypes are allowed.
+func (h *Handler) checkDataURL(dataURL string) (string, string) {
+	if !h.cfg.AllowDataURLs {
+		return "", h.invalidLongURL()
+	}
+
+	maxBytes := h.cfg.MaxDataURLBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxDataURLBytes
+	}
+	if len(dataURL) > maxBytes {
+		return "", fmt.Sprintf("Data URLs must not be longer than %d bytes", maxBytes)
+	}
+
+	header, payload, ok := strings.Cut(dataURL[len("data:"):], ",")
+	if !ok {
+		return "", "Invalid data URL. Expected data:[<media type>][;base64],<data>."
+	}
+	params := strings.Split(header, ";")
+	mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+	if !slices.Contains(dataURLMediaTypes, mediaType) {
+		return "", fmt.Sprintf("Data URLs of type %s cannot be shortened", mediaType)
+	}
+	if strings.EqualFold(params[len(params)-1], "base64") {
+		if _, err := base64.StdEncoding.DecodeString(payload); err != nil {
+			return "", "Invalid data URL. The base64 payload is malformed."
+		}
+	}
+
+	return "data:" + header + "," + payload, ""
+}
+
+// ValidateURL handles POST /api/validate, running the shorten checks on a URL without storing it
+func (h *Handler) ValidateURL(w http.ResponseWriter, r *http.Request) {
+	var req ValidateRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	var resp ValidateResponse
+	if _, reason := h.checkLongURL(req.LongURL); reason != "" {
+		resp.Reason = reason
+	} else {
+		resp.Valid = true
+	}
+	h.writeJSON(w, r, http.StatusOK, resp)
+}
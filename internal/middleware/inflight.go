@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"sync/atomic"
+)
+
+// InFlight counts requests currently being served, so shutdown can report how
+// many requests it is waiting on.
+type InFlight struct {
+	count    atomic.Int64
+	draining atomic.Bool
+}
+
+// Handler wraps next, counting each request while it is being served.
+func (f *InFlight) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.count.Add(1)
+		defer func() {
+			if f.count.Add(-1) == 0 && f.draining.Load() {
+				log.Println("All in-flight requests drained")
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Count returns the number of requests currently being served.
+func (f *InFlight) Count() int64 {
+	return f.count.Load()
+}
+
+// StartDraining logs the number of pending requests and arranges for a log line
+// once the last of them has finished.
+func (f *InFlight) StartDraining() {
+	f.draining.Store(true)
+	pending := f.count.Load()
+	log.Printf("Waiting for %d in-flight requests to finish", pending)
+	if pending == 0 {
+		log.Println("All in-flight requests drained")
+	}
+}
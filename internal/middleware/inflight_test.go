@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInFlight(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	var f InFlight
+	entered, release := make(chan struct{}), make(chan struct{})
+	blocking := f.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		blocking.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	<-entered
+	if got := f.Count(); got != 1 {
+		t.Errorf("Count during request = %d, want 1", got)
+	}
+
+	f.StartDraining()
+	if !strings.Contains(logs.String(), "Waiting for 1 in-flight requests") {
+		t.Errorf("draining log = %q, want the pending count", logs.String())
+	}
+	close(release)
+	<-done
+	if got := f.Count(); got != 0 {
+		t.Errorf("Count after request = %d, want 0", got)
+	}
+	if !strings.Contains(logs.String(), "All in-flight requests drained") {
+		t.Errorf("log = %q, want the drained message", logs.String())
+	}
+
+	// A panicking request must not stay counted
+	panicking := f.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := f.Count(); got != 1 {
+			t.Errorf("Count during panicking request = %d, want 1", got)
+		}
+		panic("boom")
+	}))
+	func() {
+		defer func() { recover() }()
+		panicking.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	if got := f.Count(); got != 0 {
+		t.Errorf("Count after panic = %d, want 0", got)
+	}
+}
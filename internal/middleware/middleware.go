@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultSecurityHeaders are applied to every response unless overridden.
+var DefaultSecurityHeaders = map[string]string{
+	"X-Content-Type-Options": "nosniff",
+	"Referrer-Policy":        "no-referrer",
+	"X-Frame-Options":        "DENY",
+}
+
+// ParseHeaders parses a "|"-separated list of "Name: value" pairs,
+// e.g. "X-Frame-Options: DENY|Referrer-Policy: no-referrer".
+func ParseHeaders(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, item := range strings.Split(s, "|") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(item, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q: expected \"Name: value\"", item)
+		}
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	return headers, nil
+}
+
+// SecurityHeaders sets the given headers on every response served by next.
+func SecurityHeaders(headers map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"X-Frame-Options: DENY|referrer-policy: no-referrer", map[string]string{"X-Frame-Options": "DENY", "Referrer-Policy": "no-referrer"}, false},
+		{" Content-Security-Policy : default-src 'self'; img-src * | ", map[string]string{"Content-Security-Policy": "default-src 'self'; img-src *"}, false},
+		{"", map[string]string{}, false},
+		{"X-Frame-Options", nil, true},
+		{": DENY", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHeaders(tt.in)
+		if (err != nil) != tt.wantErr || !maps.Equal(got, tt.want) {
+			t.Errorf("ParseHeaders(%q) = %v, %v; want %v, error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		next       http.Handler
+		wantStatus int
+	}{
+		{"normal response", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}), http.StatusOK},
+		{"error response", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "not found", http.StatusNotFound)
+		}), http.StatusNotFound},
+		{"recovered panic", Recover(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})), http.StatusInternalServerError},
+	}
+
+	headers := map[string]string{"X-Custom": "1"}
+	maps.Copy(headers, DefaultSecurityHeaders)
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		SecurityHeaders(headers, tt.next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+		for name, value := range headers {
+			if got := w.Header().Get(name); got != value {
+				t.Errorf("%s: %s = %q, want %q", tt.name, name, got, value)
+			}
+		}
+	}
+}
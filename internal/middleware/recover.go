@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover turns a panic in next into a logged 500 instead of a dropped connection. The
+// response is written by report, or sent as a JSON error when report is nil.
+func Recover(report http.HandlerFunc, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tw := &trackingWriter{ResponseWriter: w}
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// Deliberate aborts are left to net/http
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			id := RequestIDFrom(r.Context())
+			log.Printf("Panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, id, err, debug.Stack())
+
+			// Nothing can be reported if the handler already started its response, so
+			// abort it rather than let a truncated response pass as complete
+			if tw.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			if report != nil {
+				report(w, r)
+				return
+			}
+			body := map[string]string{"error": "Internal server error"}
+			if id != "" {
+				body["request_id"] = id
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(body)
+		}()
+
+		next.ServeHTTP(tw, r)
+	})
+}
+
+// trackingWriter records whether the response has been started.
+type trackingWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *trackingWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	panicBefore := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	panicAfter := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		panic("boom")
+	})
+	report := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+
+	tests := []struct {
+		name       string
+		report     http.HandlerFunc
+		next       http.Handler
+		wantStatus int
+		wantBody   string
+		wantAbort  bool
+	}{
+		{name: "panic before response", next: panicBefore, wantStatus: http.StatusInternalServerError},
+		{name: "panic before response with report", report: report, next: panicBefore, wantStatus: http.StatusTeapot},
+		{name: "panic after WriteHeader", next: panicAfter, wantStatus: http.StatusOK, wantBody: "partial", wantAbort: true},
+		{name: "panic after WriteHeader with report", report: report, next: panicAfter, wantStatus: http.StatusOK, wantBody: "partial", wantAbort: true},
+		{name: "no panic", next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }), wantStatus: http.StatusOK, wantBody: "ok"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			aborted := func() (aborted bool) {
+				defer func() {
+					if err := recover(); err != nil {
+						if err != http.ErrAbortHandler {
+							panic(err)
+						}
+						aborted = true
+					}
+				}()
+				RequestID(Recover(tt.report, tt.next)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+				return false
+			}()
+
+			if aborted != tt.wantAbort {
+				t.Errorf("aborted = %v, want %v", aborted, tt.wantAbort)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body, tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusInternalServerError {
+				var body map[string]string
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["request_id"] != w.Header().Get(RequestIDHeader) {
+					t.Errorf("body = %s, want a JSON error with the request ID", w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestRecoverPassesAborts(t *testing.T) {
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", err)
+		}
+	}()
+	Recover(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
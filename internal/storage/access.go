@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Access is a single recorded visit to a short link.
+type Access struct {
+	AccessedAt time.Time
+	IP         string
+	UserAgent  string
+	Referer    string
+}
+
+// ReferrerCount is the number of recorded visits that came from one referer.
+type ReferrerCount struct {
+	// Referer is empty for visits without a Referer header.
+	Referer string
+	Count   int64
+}
+
+// RecordAccess stores a visit to shortID in the access log.
+func (s *Storage) RecordAccess(ctx context.Context, shortID string, access Access) error {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
+
+	stmt := `INSERT INTO access_log (short_id, ip, user_agent, referer) VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''))`
+	if _, err := s.db.Exec(ctx, stmt, shortID, access.IP, access.UserAgent, access.Referer); err != nil {
+		log.Printf("Error recording access to short ID '%s': %v", shortID, err)
+		return fmt.Errorf("failed to record access: %w", err)
+	}
+	return nil
+}
+
+// Accesses returns up to limit visits to shortID, newest first, skipping the first offset.
+func (s *Storage) Accesses(ctx context.Context, shortID string, limit, offset int) ([]Access, error) {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT accessed_at, COALESCE(ip, ''), COALESCE(user_agent, ''), COALESCE(referer, '')
+		FROM access_log WHERE short_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3`
+	rows, err := s.db.Query(ctx, stmt, shortID, limit, offset)
+	if err != nil {
+		log.Printf("Error loading accesses for short ID '%s': %v", shortID, err)
+		return nil, fmt.Errorf("failed to load accesses: %w", err)
+	}
+	defer rows.Close()
+
+	accesses := []Access{}
+	for rows.Next() {
+		var access Access
+		if err := rows.Scan(&access.AccessedAt, &access.IP, &access.UserAgent, &access.Referer); err != nil {
+			return nil, fmt.Errorf("failed to load accesses: %w", err)
+		}
+		accesses = append(accesses, access)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load accesses: %w", err)
+	}
+
+	return accesses, nil
+}
+
+// TopReferrers returns the limit most common referers of visits to shortID, most frequent first.
+// Visits without a referer are counted together under an empty Referer.
+func (s *Storage) TopReferrers(ctx context.Context, shortID string, limit int) ([]ReferrerCount, error) {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT COALESCE(referer, '') AS ref, COUNT(*) AS visits
+		FROM access_log WHERE short_id = $1 GROUP BY ref ORDER BY visits DESC, ref LIMIT $2`
+	rows, err := s.db.Query(ctx, stmt, shortID, limit)
+	if err != nil {
+		log.Printf("Error loading referrers for short ID '%s': %v", shortID, err)
+		return nil, fmt.Errorf("failed to load referrers: %w", err)
+	}
+	defer rows.Close()
+
+	referrers := []ReferrerCount{}
+	for rows.Next() {
+		var referrer ReferrerCount
+		if err := rows.Scan(&referrer.Referer, &referrer.Count); err != nil {
+			return nil, fmt.Errorf("failed to load referrers: %w", err)
+		}
+		referrers = append(referrers, referrer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load referrers: %w", err)
+	}
+
+	return referrers, nil
+}
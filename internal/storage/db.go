@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Supported database drivers.
+const (
+	DriverStdlib  = "stdlib"
+	DriverPgxpool = "pgxpool"
+)
+
+// rowScanner is a single result row.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// rowsScanner is a set of result rows.
+type rowsScanner interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+	Close() error
+}
+
+// dbConn is the thin layer Storage runs its queries through, so the same queries
+// work over database/sql and over a native pgx pool. Row lookups report a missing
+// row as sql.ErrNoRows for both.
+type dbConn interface {
+	Exec(ctx context.Context, query string, args ...any) (int64, error)
+	QueryRow(ctx context.Context, query string, args ...any) rowScanner
+	Query(ctx context.Context, query string, args ...any) (rowsScanner, error)
+	Ping(ctx context.Context) error
+	Stats() PoolStats
+	Close() error
+}
+
+// openDB opens a connection pool for dsn using the given driver.
+func openDB(ctx context.Context, driver, dsn string) (dbConn, error) {
+	switch driver {
+	case "", DriverStdlib:
+		db, err := sql.Open("pgx", dsn)
+		if err != nil {
+			return nil, err
+		}
+		return sqlConn{db}, nil
+	case DriverPgxpool:
+		pool, err := pgxpool.New(ctx, dsn)
+		if err != nil {
+			return nil, err
+		}
+		return pgxConn{pool}, nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q: must be %s or %s", driver, DriverStdlib, DriverPgxpool)
+	}
+}
+
+// connect opens a connection pool for dsn and verifies it with a ping, giving up once
+// timeout has passed so an unreachable database fails startup quickly.
+func connect(ctx context.Context, driver, dsn string, timeout time.Duration) (dbConn, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	db, err := openDB(ctx, driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
+	}
+	if err := db.Ping(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database within %s: %w", timeout, err)
+	}
+	return db, nil
+}
+
+// driverName returns the effective name of driver, resolving the default.
+func driverName(driver string) string {
+	if driver == "" {
+		return DriverStdlib
+	}
+	return driver
+}
+
+// sqlConn runs queries through database/sql with the pgx stdlib driver.
+type sqlConn struct {
+	db *sql.DB
+}
+
+func (c sqlConn) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	res, err := c.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (c sqlConn) QueryRow(ctx context.Context, query string, args ...any) rowScanner {
+	return c.db.QueryRowContext(ctx, query, args...)
+}
+
+func (c sqlConn) Query(ctx context.Context, query string, args ...any) (rowsScanner, error) {
+	return c.db.QueryContext(ctx, query, args...)
+}
+
+func (c sqlConn) Ping(ctx context.Context) error {
+	return c.db.PingContext(ctx)
+}
+
+func (c sqlConn) Stats() PoolStats {
+	stats := c.db.Stats()
+	return PoolStats{
+		OpenConnections: stats.OpenConnections,
+		InUse:           stats.InUse,
+		Idle:            stats.Idle,
+		WaitCount:       stats.WaitCount,
+		WaitDuration:    stats.WaitDuration,
+	}
+}
+
+func (c sqlConn) Close() error {
+	return c.db.Close()
+}
+
+// pgxConn runs queries directly on a pgxpool.Pool.
+type pgxConn struct {
+	pool *pgxpool.Pool
+}
+
+func (c pgxConn) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	tag, err := c.pool.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (c pgxConn) QueryRow(ctx context.Context, query string, args ...any) rowScanner {
+	return pgxRow{c.pool.QueryRow(ctx, query, args...)}
+}
+
+func (c pgxConn) Query(ctx context.Context, query string, args ...any) (rowsScanner, error) {
+	rows, err := c.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgxRows{rows}, nil
+}
+
+func (c pgxConn) Ping(ctx context.Context) error {
+	return c.pool.Ping(ctx)
+}
+
+func (c pgxConn) Stats() PoolStats {
+	stats := c.pool.Stat()
+	return PoolStats{
+		OpenConnections: int(stats.TotalConns()),
+		InUse:           int(stats.AcquiredConns()),
+		Idle:            int(stats.IdleConns()),
+		WaitCount:       stats.EmptyAcquireCount(),
+		WaitDuration:    stats.EmptyAcquireWaitTime(),
+	}
+}
+
+func (c pgxConn) Close() error {
+	c.pool.Close()
+	return nil
+}
+
+// pgxRow translates pgx.ErrNoRows into sql.ErrNoRows.
+type pgxRow struct {
+	row pgx.Row
+}
+
+func (r pgxRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// pgxRows adapts pgx.Rows to rowsScanner.
+type pgxRows struct {
+	pgx.Rows
+}
+
+func (r pgxRows) Close() error {
+	r.Rows.Close()
+	return nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConnectTimesOut(t *testing.T) {
+	// The listener accepts connections but never answers, like a database that hangs on startup
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	dsn := fmt.Sprintf("host=127.0.0.1 port=%d user=test dbname=test sslmode=disable connect_timeout=10", addr.Port)
+
+	const timeout = 200 * time.Millisecond
+	for _, driver := range []string{DriverStdlib, DriverPgxpool} {
+		start := time.Now()
+		db, err := connect(context.Background(), driver, dsn, timeout)
+		elapsed := time.Since(start)
+
+		if err == nil {
+			db.Close()
+			t.Fatalf("%s: connect succeeded against a silent server", driver)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: connect error = %v, want %v", driver, err, context.DeadlineExceeded)
+		}
+		if elapsed > 5*timeout {
+			t.Errorf("%s: connect took %s with a %s timeout", driver, elapsed, timeout)
+		}
+	}
+}
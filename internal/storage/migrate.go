@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// migrations bring the schema up to date on startup. They run in order on every
+// start and must be idempotent, so new statements are only ever appended.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS urls (
+		short_id TEXT PRIMARY KEY,
+		long_url TEXT NOT NULL
+	)`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS domain TEXT`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirect_status SMALLINT`,
+	// Rows that predate this column are stamped with the time of the migration
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now()`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS creator_ip TEXT`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS creator_ua TEXT`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS description TEXT`,
+	// Deleted and rotated-away IDs, kept so they are not reissued during the cooldown
+	`CREATE TABLE IF NOT EXISTS retired_ids (
+		short_id TEXT PRIMARY KEY,
+		retired_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ`,
+	`CREATE TABLE IF NOT EXISTS access_log (
+		id BIGSERIAL PRIMARY KEY,
+		short_id TEXT NOT NULL,
+		accessed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		ip TEXT,
+		user_agent TEXT,
+		referer TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS access_log_short_id_idx ON access_log (short_id, id DESC)`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirectable BOOLEAN NOT NULL DEFAULT TRUE`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS source TEXT`,
+	// Access history follows its link: deleted with it and carried over on rotation, so a
+	// reissued ID never inherits another link's visitors. Orphans are dropped first.
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'access_log_short_id_fkey') THEN
+			DELETE FROM access_log a WHERE NOT EXISTS (SELECT 1 FROM urls u WHERE u.short_id = a.short_id);
+			ALTER TABLE access_log ADD CONSTRAINT access_log_short_id_fkey
+				FOREIGN KEY (short_id) REFERENCES urls (short_id) ON DELETE CASCADE ON UPDATE CASCADE;
+		END IF;
+	END $$`,
+	// Nothing sweeps expired links yet, so drop the index an earlier build added for it
+	`DROP INDEX IF EXISTS urls_expires_at_idx`,
+}
+
+// migrate applies all schema migrations to db.
+func migrate(ctx context.Context, db dbConn) error {
+	for i, stmt := range migrations {
+		if _, err := db.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+	}
+
+	log.Printf("Database schema is up to date (%d migrations).", len(migrations))
+	return nil
+}
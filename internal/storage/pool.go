@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PoolStats is a snapshot of the database connection pool.
+type PoolStats struct {
+	OpenConnections int
+	InUse           int
+	Idle            int
+	WaitCount       int64
+	WaitDuration    time.Duration
+	TakenAt         time.Time
+}
+
+// MonitorPool logs connection pool statistics every interval until ctx is done.
+// The most recent snapshot is returned by LastPoolStats.
+func (s *Storage) MonitorPool(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		stats := s.currentPoolStats()
+		s.lastPoolStats.Store(&stats)
+		log.Printf("DB pool stats: open=%d in_use=%d idle=%d wait_count=%d wait_duration=%s",
+			stats.OpenConnections, stats.InUse, stats.Idle, stats.WaitCount, stats.WaitDuration)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// LastPoolStats returns the snapshot last recorded by MonitorPool,
+// or the current statistics when the monitor is not running.
+func (s *Storage) LastPoolStats() PoolStats {
+	if stats := s.lastPoolStats.Load(); stats != nil {
+		return *stats
+	}
+	return s.currentPoolStats()
+}
+
+func (s *Storage) currentPoolStats() PoolStats {
+	stats := s.db.Stats()
+	stats.TakenAt = time.Now()
+	return stats
+}
@@ -6,50 +6,218 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
-const shortIDLength = 6
+// DefaultShortIDLength is the length of generated IDs, excluding any checksum character.
+const DefaultShortIDLength = 6
 const uniqueViolationCode = "23505"
+const deadlockDetectedCode = "40P01"
+const maxSaveAttempts = 5
+
+// maxShortSaveAttempts is the collision budget for IDs shorter than the default, whose keyspace is small.
+const maxShortSaveAttempts = 20
+
+// maxBlacklistRetries bounds regeneration of IDs that contain a blacklisted substring.
+const maxBlacklistRetries = 100
+const maxDeadlockRetries = 3
+const defaultPingTimeout = 5 * time.Second
+
+// ErrNotFound is returned when a short ID does not exist.
+var ErrNotFound = errors.New("short ID not found")
+
+// ErrShortIDExhausted is returned when no unused short ID could be generated within the retry budget.
+var ErrShortIDExhausted = errors.New("failed to generate a unique short ID after multiple attempts")
+
+// ErrLinkExpired is returned by Load for a link past its expiry. It also matches ErrNotFound.
+var ErrLinkExpired = errors.New("short link has expired")
+
+// ErrLinkLimitReached is returned by Save when the configured total link limit is reached.
+var ErrLinkLimitReached = errors.New("maximum number of links reached")
+
+const (
+	lowerChars = "abcdefghijklmnopqrstuvwxyz"
+	upperChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars = "0123456789"
+	// base32Chars is Crockford's alphabet, which leaves out the easily confused i, l, o and u
+	base32Chars = "0123456789abcdefghjkmnpqrstvwxyz"
+	hexChars    = "0123456789abcdef"
+)
+
+// Supported short ID encodings.
+const (
+	EncodingBase62 = "base62"
+	EncodingBase32 = "base32"
+	EncodingHex    = "hex"
+)
+
+// Link is a stored short link.
+type Link struct {
+	ShortID string
+	LongURL string
+	// Domain restricts the link to requests for this host. Empty means any host.
+	Domain string
+	// RedirectStatus is the HTTP status used when redirecting. Zero means the default.
+	RedirectStatus int
+	// CreatorIP and CreatorUA identify the client that created the link, if recorded.
+	CreatorIP string
+	CreatorUA string
+	// Description is the creator's free-form note about the link.
+	Description string
+	// ExpiresAt is when the link stops resolving. The zero time means never.
+	ExpiresAt time.Time
+	// NotesOnly links are stored, listed and resolvable through the API but never redirect.
+	NotesOnly bool
+	// Source records how the link was created, such as "api" or "form". Empty means unknown.
+	Source string
+	// IDLength requests a generated ID of this length when saving. Zero means DefaultShortIDLength.
+	IDLength int
+}
+
+// Config holds optional storage settings.
+type Config struct {
+	// ShortIDCase restricts generated IDs to "mixed", "lower" or "upper" case letters.
+	// The base32 and hex encodings are single-case, so "mixed" means lower case for them.
+	ShortIDCase string
+	// IDEncoding selects the alphabet of generated IDs: EncodingBase62 (default), EncodingBase32 or EncodingHex.
+	IDEncoding string
+	// IDStrategy selects how IDs are generated: IDStrategyRandom (default) draws characters from the
+	// encoding's alphabet, IDStrategyWords builds aliases such as "happy-blue-tiger" from embedded word lists.
+	IDStrategy string
+	// StatementTimeout bounds each storage operation. Zero means no limit beyond the caller's context.
+	StatementTimeout time.Duration
+	// MaxTotalLinks caps the number of stored links. Zero means unlimited.
+	MaxTotalLinks int64
+	// Driver selects the database driver: DriverStdlib (default) or DriverPgxpool.
+	Driver string
+	// ReplicaDSN points link lookups at a read replica. Empty reads from the primary.
+	ReplicaDSN string
+	// PingTimeout bounds the initial connection check. Zero uses the default of 5 seconds.
+	PingTimeout time.Duration
+	// MaxFillRatio is the share of the ID keyspace that may be in use before generated IDs
+	// automatically grow by a character. Zero disables the check.
+	MaxFillRatio float64
+	// IDBlacklist lists substrings generated IDs must not contain, matched case-insensitively.
+	IDBlacklist []string
+	// ShortIDChecksum appends a check character to generated IDs so typos can be rejected early.
+	ShortIDChecksum bool
+	// IDCooldownDays keeps deleted and rotated-away IDs from being reissued for this many days.
+	// Zero disables the cooldown and retired IDs are not recorded.
+	IDCooldownDays int
+}
+
+// likeEscaper escapes the LIKE wildcards and the escape character itself.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
 
 type Storage struct {
-	db *sql.DB
-	r  *rand.Rand
+	db               dbConn
+	replica          dbConn
+	r                *rand.Rand
+	charset          string
+	idStrategy       string
+	statementTimeout time.Duration
+	maxTotalLinks    int64
+	checksum         bool
+	maxFillRatio     float64
+	blacklist        atomic.Pointer[[]string]
+	idCooldownDays   int
+	driver           string
+
+	// linkCount caches the number of stored links for the total link limit; -1 until loaded.
+	countMu   sync.Mutex
+	linkCount int64
+
+	// idLength is the generated ID length last chosen by expandedLength
+	idLength atomic.Int64
+
+	// lastPoolStats holds the latest snapshot recorded by MonitorPool
+	lastPoolStats atomic.Pointer[PoolStats]
 }
 
-func NewStorage(dsn string) (*Storage, error) {
-	// Open database connection
-	db, err := sql.Open("pgx", dsn)
+func NewStorage(dsn string, cfg Config) (*Storage, error) {
+	charset, err := charsetFor(cfg.IDEncoding, cfg.ShortIDCase)
+	if err != nil {
+		return nil, err
+	}
+	idStrategy, err := validIDStrategy(cfg.IDStrategy)
+	if err != nil {
+		return nil, err
+	}
+	if idStrategy == IDStrategyWords && cfg.ShortIDChecksum {
+		return nil, errors.New("short ID checksums are not supported with word aliases")
+	}
+	if len(charset) < len(lowerChars+upperChars+digitChars) && idStrategy == IDStrategyRandom {
+		log.Printf("Warning: short ID encoding %q with case %q reduces the keyspace to %d characters per position", cfg.IDEncoding, cfg.ShortIDCase, len(charset))
+	}
+
+	pingTimeout := cfg.PingTimeout
+	if pingTimeout <= 0 {
+		pingTimeout = defaultPingTimeout
+	}
+	db, err := connect(context.Background(), cfg.Driver, dsn, pingTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database connection: %w", err)
+		return nil, err
 	}
 
-	// Verify the connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	log.Printf("Database connection established successfully (driver: %s).", driverName(cfg.Driver))
 
-	if err = db.PingContext(ctx); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	var replica dbConn
+	if cfg.ReplicaDSN != "" {
+		if replica, err = connect(context.Background(), cfg.Driver, cfg.ReplicaDSN, pingTimeout); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to connect to read replica: %w", err)
+		}
+		log.Println("Read replica connection established successfully.")
 	}
 
-	log.Println("Database connection established successfully.")
+	migrateCtx, cancelMigrate := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelMigrate()
+
+	if err = migrate(migrateCtx, db); err != nil {
+		if replica != nil {
+			replica.Close()
+		}
+		db.Close()
+		return nil, err
+	}
 
 	source := rand.NewSource(time.Now().UnixNano())
 	randomGenerator := rand.New(source)
 
-	return &Storage{
-		db: db,
-		r:  randomGenerator,
-	}, nil
+	s := &Storage{
+		db:               db,
+		replica:          replica,
+		r:                randomGenerator,
+		charset:          charset,
+		idStrategy:       idStrategy,
+		statementTimeout: cfg.StatementTimeout,
+		maxTotalLinks:    cfg.MaxTotalLinks,
+		checksum:         cfg.ShortIDChecksum,
+		maxFillRatio:     cfg.MaxFillRatio,
+		idCooldownDays:   cfg.IDCooldownDays,
+		driver:           driverName(cfg.Driver),
+		linkCount:        -1,
+	}
+	s.SetIDBlacklist(cfg.IDBlacklist)
+	return s, nil
 }
 
 // Close releases the database connection pool.
 func (s *Storage) Close() error {
+	if s.replica != nil {
+		log.Println("Closing read replica connection pool.")
+		if err := s.replica.Close(); err != nil {
+			log.Printf("Error closing read replica connection pool: %v", err)
+		}
+	}
 	if s.db != nil {
 		log.Println("Closing database connection pool.")
 		return s.db.Close()
@@ -57,14 +225,91 @@ func (s *Storage) Close() error {
 	return nil
 }
 
-func (s *Storage) Save(ctx context.Context, longURL string) (string, error) {
-	for i := 0; i < 5; i++ {
-		shortID := s.generateShortID()
+// Settings describes the effective storage configuration. It holds nothing secret.
+type Settings struct {
+	Driver         string
+	IDStrategy     string
+	ShortIDLength  int
+	CharsetSize    int
+	Checksum       bool
+	MaxTotalLinks  int64
+	MaxFillRatio   float64
+	IDCooldownDays int
+}
+
+// Settings returns the effective storage configuration, including the current
+// generated ID length when it has grown past the default.
+func (s *Storage) Settings() Settings {
+	length := DefaultShortIDLength
+	if expanded := int(s.idLength.Load()); expanded > length {
+		length = expanded
+	}
+	return Settings{
+		Driver:         s.driver,
+		IDStrategy:     s.idStrategy,
+		ShortIDLength:  length,
+		CharsetSize:    len(s.charset),
+		Checksum:       s.checksum,
+		MaxTotalLinks:  s.maxTotalLinks,
+		MaxFillRatio:   s.maxFillRatio,
+		IDCooldownDays: s.idCooldownDays,
+	}
+}
+
+// withStatementTimeout bounds ctx by the configured statement timeout, if any.
+func (s *Storage) withStatementTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.statementTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.statementTimeout)
+}
+
+// Save stores link under a newly generated short ID and returns that ID.
+func (s *Storage) Save(ctx context.Context, link Link) (string, error) {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
+
+	if s.maxTotalLinks > 0 {
+		count, err := s.cachedLinkCount(ctx)
+		if err != nil {
+			return "", err
+		}
+		if count >= s.maxTotalLinks {
+			return "", ErrLinkLimitReached
+		}
+	}
+
+	length, err := s.newIDLength(ctx, link.IDLength)
+	if err != nil {
+		return "", err
+	}
+	attempts := saveAttempts(length)
+
+	// A nil expiry is stored as NULL
+	var expiresAt any
+	if !link.ExpiresAt.IsZero() {
+		expiresAt = link.ExpiresAt
+	}
+
+	deadlocks := 0
+	for i := 0; i < attempts; i++ {
+		shortID := s.generateShortID(length)
+		if shortID == "" {
+			continue
+		}
+
+		if retired, err := s.isInCooldown(ctx, shortID); err != nil {
+			return "", fmt.Errorf("failed to save URL to database: %w", err)
+		} else if retired {
+			continue
+		}
 
-		stmt := `INSERT INTO urls (short_id, long_url) VALUES ($1, $2)`
+		stmt := `INSERT INTO urls (short_id, long_url, domain, redirect_status, creator_ip, creator_ua, description, expires_at, redirectable, source)
+			VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, 0), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8, $9, NULLIF($10, ''))`
 		// Execute the INSERT statement
-		_, err := s.db.ExecContext(ctx, stmt, shortID, longURL)
+		_, err := s.db.Exec(ctx, stmt, shortID, link.LongURL, link.Domain, link.RedirectStatus, link.CreatorIP, link.CreatorUA, link.Description, expiresAt, !link.NotesOnly, link.Source)
 		if err == nil {
+			s.addLinkCount(1)
 			return shortID, nil
 		}
 
@@ -75,39 +320,530 @@ func (s *Storage) Save(ctx context.Context, longURL string) (string, error) {
 			continue
 		}
 
+		// Deadlocks under concurrent inserts are transient, retry them on their own budget
+		if errors.As(err, &pgErr) && pgErr.Code == deadlockDetectedCode && deadlocks < maxDeadlockRetries {
+			deadlocks++
+			log.Printf("Deadlock detected saving short ID '%s', retrying (%d/%d)...", shortID, deadlocks, maxDeadlockRetries)
+			if err := sleepBackoff(ctx, deadlocks); err != nil {
+				return "", fmt.Errorf("failed to save URL to database: %w", err)
+			}
+			i--
+			continue
+		}
+
 		// Other database error occurred
 		log.Printf("Error saving URL to database: %v", err)
 		return "", fmt.Errorf("failed to save URL to database: %w", err)
 	}
 
-	return "", errors.New("failed to generate a unique short ID after multiple attempts")
+	return "", ErrShortIDExhausted
 }
 
-func (s *Storage) Load(ctx context.Context, shortID string) (string, error) {
-	var longURL string
+// Load returns the link stored under shortID.
+func (s *Storage) Load(ctx context.Context, shortID string) (Link, error) {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT long_url FROM urls WHERE short_id = $1`
-	row := s.db.QueryRowContext(ctx, stmt, shortID)
+	if s.replica == nil {
+		return s.load(ctx, s.db, shortID)
+	}
 
-	err := row.Scan(&longURL)
+	link, err := s.load(ctx, s.replica, shortID)
+	if errors.Is(err, ErrNotFound) && !errors.Is(err, ErrLinkExpired) {
+		// The replica may not have caught up with a link created moments ago
+		return s.load(ctx, s.db, shortID)
+	}
+	return link, err
+}
+
+// load looks up shortID on db.
+func (s *Storage) load(ctx context.Context, db dbConn, shortID string) (Link, error) {
+	link := Link{ShortID: shortID}
+
+	stmt := `SELECT long_url, COALESCE(domain, ''), COALESCE(redirect_status, 0), COALESCE(description, ''), expires_at, NOT redirectable
+		FROM urls WHERE short_id = $1`
+	row := db.QueryRow(ctx, stmt, shortID)
+
+	var expiresAt sql.NullTime
+	err := row.Scan(&link.LongURL, &link.Domain, &link.RedirectStatus, &link.Description, &expiresAt, &link.NotesOnly)
 	if err != nil {
 		// shortID is not found
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", fmt.Errorf("short ID not found: %s", shortID)
+			return Link{}, fmt.Errorf("%w: %s", ErrNotFound, shortID)
 		}
 		// Other database error occurred
 		log.Printf("Error loading URL from database: %v", err)
-		return "", fmt.Errorf("failed to load URL from database: %w", err)
+		return Link{}, fmt.Errorf("failed to load URL from database: %w", err)
+	}
+
+	if expiresAt.Valid {
+		link.ExpiresAt = expiresAt.Time
+		if !time.Now().Before(link.ExpiresAt) {
+			return Link{}, fmt.Errorf("%w: %w: %s", ErrLinkExpired, ErrNotFound, shortID)
+		}
+	}
+
+	return link, nil
+}
+
+// Rotate replaces the short ID of an existing link with a newly generated one,
+// keeping its destination. The old ID stops resolving once this returns.
+func (s *Storage) Rotate(ctx context.Context, shortID string) (Link, error) {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
+
+	// Random IDs keep the old ID's length, so premium and grown IDs do not shrink
+	oldLength, requested := 0, 0
+	if s.idStrategy == IDStrategyRandom {
+		oldLength = len(shortID)
+		if s.checksum && s.HasValidChecksum(shortID) {
+			oldLength--
+		}
+		if oldLength < DefaultShortIDLength {
+			requested = oldLength
+		}
+	}
+	length, err := s.newIDLength(ctx, requested)
+	if err != nil {
+		return Link{}, err
+	}
+	length = max(length, oldLength)
+
+	for i := 0; i < saveAttempts(length); i++ {
+		link := Link{ShortID: s.generateShortID(length)}
+		if link.ShortID == "" {
+			continue
+		}
+
+		if retired, err := s.isInCooldown(ctx, link.ShortID); err != nil {
+			return Link{}, fmt.Errorf("failed to rotate short ID: %w", err)
+		} else if retired {
+			continue
+		}
+
+		// A single UPDATE swaps the ID atomically
+		stmt := `UPDATE urls SET short_id = $1 WHERE short_id = $2 RETURNING long_url, COALESCE(domain, '')`
+		if s.idCooldownDays > 0 {
+			// Retire the old ID in the same statement
+			stmt = `WITH rotated AS (
+				UPDATE urls SET short_id = $1 WHERE short_id = $2 RETURNING long_url, COALESCE(domain, '') AS domain
+			), retired AS (
+				INSERT INTO retired_ids (short_id, retired_at) SELECT $2, now() FROM rotated
+				ON CONFLICT (short_id) DO UPDATE SET retired_at = EXCLUDED.retired_at
+			)
+			SELECT long_url, domain FROM rotated`
+		}
+		err := s.db.QueryRow(ctx, stmt, link.ShortID, shortID).Scan(&link.LongURL, &link.Domain)
+		if err == nil {
+			return link, nil
+		}
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return Link{}, fmt.Errorf("%w: %s", ErrNotFound, shortID)
+		}
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			log.Printf("Collision detected for short ID '%s', retrying...", link.ShortID)
+			continue
+		}
+
+		log.Printf("Error rotating short ID in database: %v", err)
+		return Link{}, fmt.Errorf("failed to rotate short ID: %w", err)
+	}
+
+	return Link{}, ErrShortIDExhausted
+}
+
+// Search returns up to limit links whose destination contains query, matched case-insensitively.
+func (s *Storage) Search(ctx context.Context, query string, limit int) ([]Link, error) {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
+
+	// Match the query literally rather than as a LIKE pattern
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	stmt := `SELECT short_id, long_url, COALESCE(domain, ''), COALESCE(redirect_status, 0), COALESCE(description, ''), NOT redirectable,
+			COALESCE(creator_ip, ''), COALESCE(creator_ua, '')
+		FROM urls WHERE long_url ILIKE $1 ESCAPE '\' ORDER BY created_at DESC LIMIT $2`
+	rows, err := s.db.Query(ctx, stmt, pattern, limit)
+	if err != nil {
+		log.Printf("Error searching links in database: %v", err)
+		return nil, fmt.Errorf("failed to search links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []Link{}
+	for rows.Next() {
+		var link Link
+		if err := rows.Scan(&link.ShortID, &link.LongURL, &link.Domain, &link.RedirectStatus, &link.Description, &link.NotesOnly,
+			&link.CreatorIP, &link.CreatorUA); err != nil {
+			return nil, fmt.Errorf("failed to search links: %w", err)
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search links: %w", err)
+	}
+
+	return links, nil
+}
+
+// Delete removes the link for shortID, returning ErrNotFound when there is none.
+func (s *Storage) Delete(ctx context.Context, shortID string) error {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
+
+	stmt := `DELETE FROM urls WHERE short_id = $1`
+	if s.idCooldownDays > 0 {
+		stmt = `WITH deleted AS (
+			DELETE FROM urls WHERE short_id = $1 RETURNING short_id
+		)
+		INSERT INTO retired_ids (short_id, retired_at) SELECT short_id, now() FROM deleted
+		ON CONFLICT (short_id) DO UPDATE SET retired_at = EXCLUDED.retired_at`
+	}
+	deleted, err := s.db.Exec(ctx, stmt, shortID)
+	if err != nil {
+		log.Printf("Error deleting link from database: %v", err)
+		return fmt.Errorf("failed to delete link: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, shortID)
+	}
+
+	s.addLinkCount(-deleted)
+	return nil
+}
+
+// SourceCount is the number of links created through one source.
+type SourceCount struct {
+	Source string
+	Count  int64
+	// Active counts the links that have not expired
+	Active int64
+}
+
+// CountBySource returns the number of stored links per creation source, most common first.
+// Links stored before sources were recorded are counted under an empty Source.
+func (s *Storage) CountBySource(ctx context.Context) ([]SourceCount, error) {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT COALESCE(source, '') AS src, COUNT(*) AS links,
+		COUNT(*) FILTER (WHERE expires_at IS NULL OR expires_at > NOW())
+		FROM urls GROUP BY src ORDER BY links DESC, src`
+	rows, err := s.db.Query(ctx, stmt)
+	if err != nil {
+		log.Printf("Error counting links by source: %v", err)
+		return nil, fmt.Errorf("failed to count links by source: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []SourceCount{}
+	for rows.Next() {
+		var count SourceCount
+		if err := rows.Scan(&count.Source, &count.Count, &count.Active); err != nil {
+			return nil, fmt.Errorf("failed to count links by source: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count links by source: %w", err)
+	}
+
+	return counts, nil
+}
+
+// CountCreatedBetween returns the number of stored links created in [from, to).
+func (s *Storage) CountCreatedBetween(ctx context.Context, from, to time.Time) (int64, error) {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	stmt := `SELECT COUNT(*) FROM urls WHERE created_at >= $1 AND created_at < $2`
+	if err := s.db.QueryRow(ctx, stmt, from, to).Scan(&count); err != nil {
+		log.Printf("Error counting links created between %s and %s: %v", from, to, err)
+		return 0, fmt.Errorf("failed to count links: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteByAge deletes links created more than olderThan ago and returns how many were removed.
+func (s *Storage) DeleteByAge(ctx context.Context, olderThan time.Duration) (int64, error) {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
+
+	cutoff := time.Now().Add(-olderThan)
+	stmt := `DELETE FROM urls WHERE created_at < $1`
+	if s.idCooldownDays > 0 {
+		// Retire the deleted IDs in the same statement; the INSERT reports one row per deleted link
+		stmt = `WITH deleted AS (
+			DELETE FROM urls WHERE created_at < $1 RETURNING short_id
+		)
+		INSERT INTO retired_ids (short_id, retired_at) SELECT short_id, now() FROM deleted
+		ON CONFLICT (short_id) DO UPDATE SET retired_at = EXCLUDED.retired_at`
+	}
+	deleted, err := s.db.Exec(ctx, stmt, cutoff)
+	if err != nil {
+		log.Printf("Error deleting links from database: %v", err)
+		return 0, fmt.Errorf("failed to delete links: %w", err)
+	}
+
+	s.addLinkCount(-deleted)
+
+	if s.idCooldownDays > 0 {
+		s.pruneRetiredIDs(ctx)
+	}
+
+	return deleted, nil
+}
+
+// newIDLength returns the length of a newly generated ID: requested when positive,
+// otherwise the default, grown while the keyspace is fuller than the fill ratio.
+// Word aliases have a fixed shape, so the fill ratio does not apply to them.
+func (s *Storage) newIDLength(ctx context.Context, requested int) (int, error) {
+	if requested > 0 {
+		return requested, nil
+	}
+	if s.maxFillRatio > 0 && s.idStrategy == IDStrategyRandom {
+		return s.expandedLength(ctx, DefaultShortIDLength)
+	}
+	return DefaultShortIDLength, nil
+}
+
+// saveAttempts returns the collision budget for IDs of length.
+func saveAttempts(length int) int {
+	if length < DefaultShortIDLength {
+		return maxShortSaveAttempts
+	}
+	return maxSaveAttempts
+}
+
+// isInCooldown reports whether shortID may not be issued yet because it was retired
+// within the cooldown. It is always false when the cooldown is disabled.
+func (s *Storage) isInCooldown(ctx context.Context, shortID string) (bool, error) {
+	if s.idCooldownDays <= 0 {
+		return false, nil
+	}
+	retired, err := s.isRecentlyRetired(ctx, shortID)
+	if retired {
+		log.Printf("Short ID '%s' was retired recently, retrying...", shortID)
+	}
+	return retired, err
+}
+
+// isRecentlyRetired reports whether shortID was deleted or rotated away within the cooldown.
+func (s *Storage) isRecentlyRetired(ctx context.Context, shortID string) (bool, error) {
+	var retired bool
+	stmt := `SELECT EXISTS (SELECT 1 FROM retired_ids WHERE short_id = $1 AND retired_at > now() - make_interval(days => $2))`
+	if err := s.db.QueryRow(ctx, stmt, shortID, s.idCooldownDays).Scan(&retired); err != nil {
+		log.Printf("Error checking retired short ID in database: %v", err)
+		return false, err
+	}
+	return retired, nil
+}
+
+// pruneRetiredIDs forgets retired IDs whose cooldown has passed. Failures are only logged.
+func (s *Storage) pruneRetiredIDs(ctx context.Context) {
+	stmt := `DELETE FROM retired_ids WHERE retired_at < now() - make_interval(days => $1)`
+	pruned, err := s.db.Exec(ctx, stmt, s.idCooldownDays)
+	if err != nil {
+		log.Printf("Error pruning retired short IDs: %v", err)
+		return
+	}
+	if pruned > 0 {
+		log.Printf("Pruned %d retired short IDs past their cooldown", pruned)
+	}
+}
+
+// expandedLength returns the smallest ID length, starting at length, whose keyspace
+// is filled below the configured ratio by the links stored so far.
+func (s *Storage) expandedLength(ctx context.Context, length int) (int, error) {
+	count, err := s.cachedLinkCount(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	expanded := length
+	for float64(count)/math.Pow(float64(len(s.charset)), float64(expanded)) > s.maxFillRatio {
+		expanded++
+	}
+
+	if previous := s.idLength.Swap(int64(expanded)); previous != int64(expanded) && expanded > length {
+		log.Printf("Short ID keyspace over %.2f%% full with %d links, generating %d-character IDs", s.maxFillRatio*100, count, expanded)
+	}
+	return expanded, nil
+}
+
+// sleepBackoff waits an exponentially growing, randomized delay before retry number attempt,
+// returning early with the context's error if it is done first.
+func sleepBackoff(ctx context.Context, attempt int) error {
+	base := 10 * time.Millisecond << (attempt - 1)
+	delay := base + time.Duration(rand.Int63n(int64(base)))
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cachedLinkCount returns the number of stored links, counting them once and caching the result.
+// The cache is a soft limit: concurrent saves may overshoot it slightly.
+func (s *Storage) cachedLinkCount(ctx context.Context) (int64, error) {
+	s.countMu.Lock()
+	defer s.countMu.Unlock()
+
+	if s.linkCount < 0 {
+		var count int64
+		if err := s.db.QueryRow(ctx, `SELECT COUNT(*) FROM urls`).Scan(&count); err != nil {
+			log.Printf("Error counting links in database: %v", err)
+			return 0, fmt.Errorf("failed to count links: %w", err)
+		}
+		s.linkCount = count
+	}
+
+	return s.linkCount, nil
+}
+
+// addLinkCount adjusts the cached link count, if it has been loaded.
+func (s *Storage) addLinkCount(delta int64) {
+	s.countMu.Lock()
+	defer s.countMu.Unlock()
+
+	if s.linkCount >= 0 {
+		s.linkCount += delta
+	}
+}
+
+// generateShortID returns a new random ID or word alias free of blacklisted substrings,
+// or "" if none was found within maxBlacklistRetries attempts.
+func (s *Storage) generateShortID(length int) string {
+	var id string
+	for attempt := 0; attempt < maxBlacklistRetries; attempt++ {
+		if s.idStrategy == IDStrategyWords {
+			if id = s.generateWordAlias(); !s.isBlacklisted(id) {
+				return id
+			}
+			continue
+		}
+
+		b := make([]byte, length)
+		for i := range b {
+			b[i] = s.charset[s.r.Intn(len(s.charset))]
+		}
+		if s.checksum {
+			b = append(b, checksumChar(b, s.charset))
+		}
+
+		id = string(b)
+		if !s.isBlacklisted(id) {
+			return id
+		}
 	}
 
-	return longURL, nil
+	log.Printf("Warning: could not generate a short ID free of blacklisted strings after %d attempts", maxBlacklistRetries)
+	return ""
+}
+
+// SetIDBlacklist replaces the substrings generated IDs must not contain. It is safe to
+// call while IDs are being generated.
+func (s *Storage) SetIDBlacklist(entries []string) {
+	blacklist := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry != "" {
+			blacklist = append(blacklist, strings.ToLower(entry))
+		}
+	}
+	s.blacklist.Store(&blacklist)
+}
+
+// isBlacklisted reports whether id contains any blacklisted substring.
+func (s *Storage) isBlacklisted(id string) bool {
+	blacklist := s.blacklist.Load()
+	if blacklist == nil || len(*blacklist) == 0 {
+		return false
+	}
+
+	lower := strings.ToLower(id)
+	for _, entry := range *blacklist {
+		if strings.Contains(lower, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasValidChecksum reports whether the last character of shortID is its check character.
+// It always returns true when checksums are disabled.
+func (s *Storage) HasValidChecksum(shortID string) bool {
+	if !s.checksum {
+		return true
+	}
+	if len(shortID) < 2 || strings.IndexByte(s.charset, shortID[len(shortID)-1]) < 0 {
+		return false
+	}
+
+	body := []byte(shortID[:len(shortID)-1])
+	for _, c := range body {
+		if strings.IndexByte(s.charset, c) < 0 {
+			return false
+		}
+	}
+	return checksumChar(body, s.charset) == shortID[len(shortID)-1]
+}
+
+// checksumChar computes the Luhn mod N check character of id over charset.
+// It detects every single-character substitution and most adjacent transpositions.
+func checksumChar(id []byte, charset string) byte {
+	n := len(charset)
+	factor := 2
+	sum := 0
+	for i := len(id) - 1; i >= 0; i-- {
+		addend := factor * strings.IndexByte(charset, id[i])
+		sum += addend/n + addend%n
+		factor = 3 - factor
+	}
+	return charset[(n-sum%n)%n]
+}
+
+// charsetFor returns the characters allowed in generated IDs for the given encoding and case setting.
+func charsetFor(encoding, idCase string) (string, error) {
+	var charset string
+	switch encoding {
+	case "", EncodingBase62:
+		return charsetForCase(idCase)
+	case EncodingBase32:
+		charset = base32Chars
+	case EncodingHex:
+		charset = hexChars
+	default:
+		return "", fmt.Errorf("invalid short ID encoding %q: must be %s, %s or %s", encoding, EncodingBase62, EncodingBase32, EncodingHex)
+	}
+
+	switch idCase {
+	case "", "mixed", "lower":
+		return charset, nil
+	case "upper":
+		return strings.ToUpper(charset), nil
+	default:
+		return "", fmt.Errorf("invalid short ID case %q: must be mixed, lower or upper", idCase)
+	}
 }
 
-func (s *Storage) generateShortID() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, shortIDLength)
-	for i := range b {
-		b[i] = charset[s.r.Intn(len(charset))]
+// charsetForCase returns the characters allowed in generated IDs for the given case setting.
+func charsetForCase(idCase string) (string, error) {
+	switch idCase {
+	case "", "mixed":
+		return lowerChars + upperChars + digitChars, nil
+	case "lower":
+		return lowerChars + digitChars, nil
+	case "upper":
+		return upperChars + digitChars, nil
+	default:
+		return "", fmt.Errorf("invalid short ID case %q: must be mixed, lower or upper", idCase)
 	}
-	return string(b)
 }
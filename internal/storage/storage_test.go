@@ -0,0 +1,635 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeDB is an in-memory dbConn that understands the INSERT, SELECT and COUNT statements
+// Save and Load issue against the urls table.
+type fakeDB struct {
+	mu   sync.Mutex
+	urls map[string]Link
+	// execErrs are returned by the next Exec calls, in order, before any statement runs
+	execErrs []error
+	execs    int
+	// lookups counts the short ID lookups
+	lookups int
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{urls: make(map[string]Link)}
+}
+
+func (db *fakeDB) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	if !strings.HasPrefix(query, "INSERT INTO urls ") {
+		return 0, errors.New("fakeDB: unsupported statement")
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.execs++
+	if len(db.execErrs) > 0 {
+		err := db.execErrs[0]
+		db.execErrs = db.execErrs[1:]
+		return 0, err
+	}
+
+	shortID := args[0].(string)
+	if _, ok := db.urls[shortID]; ok {
+		return 0, &pgconn.PgError{Code: uniqueViolationCode}
+	}
+	link := Link{
+		ShortID:        shortID,
+		LongURL:        args[1].(string),
+		Domain:         args[2].(string),
+		RedirectStatus: args[3].(int),
+		CreatorIP:      args[4].(string),
+		CreatorUA:      args[5].(string),
+		Description:    args[6].(string),
+		NotesOnly:      !args[8].(bool),
+		Source:         args[9].(string),
+	}
+	if expiresAt, ok := args[7].(time.Time); ok {
+		link.ExpiresAt = expiresAt
+	}
+	db.urls[shortID] = link
+	return 1, nil
+}
+
+func (db *fakeDB) QueryRow(ctx context.Context, query string, args ...any) rowScanner {
+	if query == `SELECT COUNT(*) FROM urls` {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		return fakeCount(len(db.urls))
+	}
+	if !strings.Contains(query, "FROM urls WHERE short_id = $1") {
+		return fakeRow{err: errors.New("fakeDB: unsupported query")}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.lookups++
+	link, ok := db.urls[args[0].(string)]
+	if !ok {
+		return fakeRow{err: sql.ErrNoRows}
+	}
+	return fakeRow{link: link}
+}
+
+func (db *fakeDB) Query(ctx context.Context, query string, args ...any) (rowsScanner, error) {
+	return nil, errors.New("fakeDB: unsupported query")
+}
+
+func (db *fakeDB) Ping(ctx context.Context) error { return nil }
+func (db *fakeDB) Stats() PoolStats               { return PoolStats{} }
+func (db *fakeDB) Close() error                   { return nil }
+
+// fakeRow scans a stored link in the column order of load.
+type fakeRow struct {
+	link Link
+	err  error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*string) = r.link.LongURL
+	*dest[1].(*string) = r.link.Domain
+	*dest[2].(*int) = r.link.RedirectStatus
+	*dest[3].(*string) = r.link.Description
+	*dest[4].(*sql.NullTime) = sql.NullTime{Time: r.link.ExpiresAt, Valid: !r.link.ExpiresAt.IsZero()}
+	*dest[5].(*bool) = r.link.NotesOnly
+	return nil
+}
+
+// fakeCount scans a single COUNT(*) result.
+type fakeCount int64
+
+func (c fakeCount) Scan(dest ...any) error {
+	*dest[0].(*int64) = int64(c)
+	return nil
+}
+
+// newTestStorage returns a Storage over db with a seeded generator and the base62 charset.
+func newTestStorage(db dbConn) *Storage {
+	return &Storage{
+		db:         db,
+		r:          rand.New(rand.NewSource(1)),
+		charset:    lowerChars + upperChars + digitChars,
+		idStrategy: IDStrategyRandom,
+		linkCount:  -1,
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name string
+		link Link
+	}{
+		{"plain", Link{LongURL: "https://example.com/"}},
+		{"vanity domain", Link{LongURL: "https://example.com/a", Domain: "go.example.org", RedirectStatus: 301}},
+		{"description", Link{LongURL: "https://example.com/b", Description: "launch post"}},
+		{"notes only", Link{LongURL: "https://example.com/c", NotesOnly: true}},
+		{"expiring", Link{LongURL: "https://example.com/d", ExpiresAt: expiresAt}},
+		{"short ID", Link{LongURL: "https://example.com/e", IDLength: 3}},
+	}
+
+	s := newTestStorage(newFakeDB())
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shortID, err := s.Save(ctx, tt.link)
+			if err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			wantLength := tt.link.IDLength
+			if wantLength == 0 {
+				wantLength = DefaultShortIDLength
+			}
+			if len(shortID) != wantLength {
+				t.Errorf("Save returned %q, want %d characters", shortID, wantLength)
+			}
+
+			got, err := s.Load(ctx, shortID)
+			if err != nil {
+				t.Fatalf("Load(%q): %v", shortID, err)
+			}
+			want := tt.link
+			want.ShortID = shortID
+			want.IDLength = 0
+			if got != want {
+				t.Errorf("Load(%q) = %+v, want %+v", shortID, got, want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	db := newFakeDB()
+	db.urls["expired"] = Link{ShortID: "expired", LongURL: "https://example.com/", ExpiresAt: time.Now().Add(-time.Minute)}
+	s := newTestStorage(db)
+
+	tests := []struct {
+		shortID string
+		want    error
+	}{
+		{"missing", ErrNotFound},
+		{"expired", ErrLinkExpired},
+		{"expired", ErrNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := s.Load(context.Background(), tt.shortID); !errors.Is(err, tt.want) {
+			t.Errorf("Load(%q) error = %v, want %v", tt.shortID, err, tt.want)
+		}
+	}
+}
+
+func TestReadReplica(t *testing.T) {
+	primary, replica := newFakeDB(), newFakeDB()
+	s := newTestStorage(primary)
+	s.replica = replica
+	ctx := context.Background()
+
+	shortID, err := s.Save(ctx, Link{LongURL: "https://example.com/primary"})
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if primary.execs != 1 || replica.execs != 0 {
+		t.Fatalf("Save ran %d inserts on the primary and %d on the replica, want 1 and 0", primary.execs, replica.execs)
+	}
+
+	// A link the replica has not caught up with yet falls back to the primary
+	if got, err := s.Load(ctx, shortID); err != nil || got.LongURL != "https://example.com/primary" {
+		t.Errorf("Load before replication = %q, %v; want the primary's link", got.LongURL, err)
+	}
+	if replica.lookups != 1 || primary.lookups != 1 {
+		t.Errorf("lookups = %d on the replica and %d on the primary, want 1 and 1", replica.lookups, primary.lookups)
+	}
+
+	// Once replicated, reads are served by the replica alone
+	replica.urls[shortID] = Link{ShortID: shortID, LongURL: "https://example.com/replica"}
+	if got, err := s.Load(ctx, shortID); err != nil || got.LongURL != "https://example.com/replica" {
+		t.Errorf("Load after replication = %q, %v; want the replica's link", got.LongURL, err)
+	}
+	if replica.lookups != 2 || primary.lookups != 1 {
+		t.Errorf("lookups = %d on the replica and %d on the primary, want 2 and 1", replica.lookups, primary.lookups)
+	}
+
+	// An expired link on the replica is final, not retried on the primary
+	replica.urls["expired"] = Link{ShortID: "expired", LongURL: "https://example.com/", ExpiresAt: time.Now().Add(-time.Minute)}
+	if _, err := s.Load(ctx, "expired"); !errors.Is(err, ErrLinkExpired) {
+		t.Errorf("Load expired error = %v, want %v", err, ErrLinkExpired)
+	}
+	if primary.lookups != 1 {
+		t.Errorf("expired lookup reached the primary")
+	}
+
+	// Without a replica, reads go to the primary
+	s.replica = nil
+	if got, err := s.Load(ctx, shortID); err != nil || got.LongURL != "https://example.com/primary" {
+		t.Errorf("Load without replica = %q, %v; want the primary's link", got.LongURL, err)
+	}
+	if primary.lookups != 2 {
+		t.Errorf("primary lookups = %d, want 2", primary.lookups)
+	}
+}
+
+func TestSaveExhaustsOnCollisions(t *testing.T) {
+	db := newFakeDB()
+	s := newTestStorage(db)
+	s.charset = "a"
+	db.urls["aaaaaa"] = Link{ShortID: "aaaaaa"}
+
+	if _, err := s.Save(context.Background(), Link{LongURL: "https://example.com/"}); !errors.Is(err, ErrShortIDExhausted) {
+		t.Errorf("Save error = %v, want %v", err, ErrShortIDExhausted)
+	}
+}
+
+func TestSaveExpandsFullKeyspace(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     int
+		strategy   string
+		idLength   int
+		wantLength int
+	}{
+		{name: "empty", stored: 0, wantLength: 6},
+		{name: "at the ratio", stored: 32, wantLength: 6},
+		{name: "over the ratio", stored: 33, wantLength: 7},
+		{name: "over the ratio at 7", stored: 65, wantLength: 8},
+		{name: "requested length", stored: 65, idLength: 4, wantLength: 4},
+		{name: "word aliases", stored: 65, strategy: IDStrategyWords},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDB()
+			for i := 0; i < tt.stored; i++ {
+				id := fmt.Sprintf("seed%d", i)
+				db.urls[id] = Link{ShortID: id}
+			}
+			s := newTestStorage(db)
+			// 2^6 = 64 six-character IDs, so 32 links fill half the keyspace
+			s.charset = "ab"
+			s.maxFillRatio = 0.5
+			if tt.strategy != "" {
+				s.idStrategy = tt.strategy
+			}
+
+			shortID, err := s.Save(context.Background(), Link{LongURL: "https://example.com/", IDLength: tt.idLength})
+			if err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if tt.strategy == IDStrategyWords {
+				if strings.Count(shortID, "-") != 2 {
+					t.Errorf("Save returned %q, want a word alias", shortID)
+				}
+				return
+			}
+			if len(shortID) != tt.wantLength {
+				t.Errorf("Save returned %q, want %d characters", shortID, tt.wantLength)
+			}
+		})
+	}
+}
+
+func TestSaveRetriesDeadlocks(t *testing.T) {
+	deadlock := &pgconn.PgError{Code: deadlockDetectedCode}
+	collision := &pgconn.PgError{Code: uniqueViolationCode}
+	repeat := func(err error, n int) []error {
+		errs := make([]error, n)
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantExecs int
+	}{
+		{"deadlocks within budget", repeat(deadlock, maxDeadlockRetries), nil, maxDeadlockRetries + 1},
+		{"deadlocks over budget", repeat(deadlock, maxDeadlockRetries+1), deadlock, maxDeadlockRetries + 1},
+		// Deadlock retries must not eat into the collision budget
+		{"deadlocks and collisions", append(repeat(collision, maxSaveAttempts-1), repeat(deadlock, maxDeadlockRetries)...), nil, maxSaveAttempts + maxDeadlockRetries},
+		{"collisions exhaust", repeat(collision, maxSaveAttempts), ErrShortIDExhausted, maxSaveAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDB()
+			db.execErrs = tt.errs
+			s := newTestStorage(db)
+
+			shortID, err := s.Save(context.Background(), Link{LongURL: "https://example.com/"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Save error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && db.urls[shortID].LongURL != "https://example.com/" {
+				t.Errorf("link %q was not stored", shortID)
+			}
+			if db.execs != tt.wantExecs {
+				t.Errorf("Save ran %d inserts, want %d", db.execs, tt.wantExecs)
+			}
+		})
+	}
+}
+
+func TestSaveSkipsBlacklistedIDs(t *testing.T) {
+	animals := aliasWords[2]
+
+	tests := []struct {
+		name      string
+		strategy  string
+		charset   string
+		blacklist []string
+		allowed   func(id string) bool
+		wantErr   error
+	}{
+		{
+			name:      "random",
+			strategy:  IDStrategyRandom,
+			charset:   "abc",
+			blacklist: []string{"A"},
+			allowed:   func(id string) bool { return !strings.Contains(id, "a") },
+		},
+		{
+			name:      "words",
+			strategy:  IDStrategyWords,
+			blacklist: animals[1:],
+			allowed:   func(id string) bool { return strings.HasSuffix(id, "-"+animals[0]) },
+		},
+		{
+			name:      "random all blacklisted",
+			strategy:  IDStrategyRandom,
+			charset:   "ab",
+			blacklist: []string{"a", "b"},
+			wantErr:   ErrShortIDExhausted,
+		},
+		{
+			name:      "words all blacklisted",
+			strategy:  IDStrategyWords,
+			blacklist: aliasWords[1],
+			wantErr:   ErrShortIDExhausted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDB()
+			s := newTestStorage(db)
+			s.idStrategy = tt.strategy
+			if tt.charset != "" {
+				s.charset = tt.charset
+			}
+			s.SetIDBlacklist(tt.blacklist)
+
+			for i := 0; i < 10; i++ {
+				_, err := s.Save(context.Background(), Link{LongURL: "https://example.com/"})
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Save error = %v, want %v", err, tt.wantErr)
+				}
+			}
+			if tt.wantErr != nil && db.execs != 0 {
+				t.Errorf("Save ran %d inserts, want none", db.execs)
+			}
+			for shortID := range db.urls {
+				if s.isBlacklisted(shortID) || !tt.allowed(shortID) {
+					t.Errorf("stored blacklisted ID %q", shortID)
+				}
+			}
+		})
+	}
+}
+
+// onlyChars reports whether every character of id is in allowed.
+func onlyChars(id, allowed string) bool {
+	for i := 0; i < len(id); i++ {
+		if strings.IndexByte(allowed, id[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestShortIDCase(t *testing.T) {
+	tests := []struct {
+		idCase  string
+		allowed string
+		wantErr bool
+	}{
+		{"", lowerChars + upperChars + digitChars, false},
+		{"mixed", lowerChars + upperChars + digitChars, false},
+		{"lower", "abcdefghijklmnopqrstuvwxyz0123456789", false},
+		{"upper", "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", false},
+		{"title", "", true},
+	}
+	for _, tt := range tests {
+		charset, err := charsetFor(EncodingBase62, tt.idCase)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("charsetFor(%q) error = %v, want error %t", tt.idCase, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+
+		s := newTestStorage(nil)
+		s.charset = charset
+		for i := 0; i < 200; i++ {
+			if id := s.generateShortID(DefaultShortIDLength); !onlyChars(id, tt.allowed) {
+				t.Fatalf("case %q generated %q, want only %q", tt.idCase, id, tt.allowed)
+			}
+		}
+	}
+}
+
+func TestShortIDEncoding(t *testing.T) {
+	tests := []struct {
+		encoding string
+		idCase   string
+		allowed  string
+		wantErr  bool
+	}{
+		{EncodingBase62, "mixed", lowerChars + upperChars + digitChars, false},
+		{EncodingBase32, "", "0123456789abcdefghjkmnpqrstvwxyz", false},
+		{EncodingBase32, "upper", "0123456789ABCDEFGHJKMNPQRSTVWXYZ", false},
+		{EncodingHex, "lower", "0123456789abcdef", false},
+		{EncodingHex, "upper", "0123456789ABCDEF", false},
+		{"base64", "", "", true},
+	}
+	for _, tt := range tests {
+		charset, err := charsetFor(tt.encoding, tt.idCase)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("charsetFor(%q, %q) error = %v, want error %t", tt.encoding, tt.idCase, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+
+		s := newTestStorage(newFakeDB())
+		s.charset = charset
+		for i := 0; i < 20; i++ {
+			link := Link{LongURL: fmt.Sprintf("https://example.com/%d", i)}
+			shortID, err := s.Save(context.Background(), link)
+			if err != nil {
+				t.Fatalf("%s: Save: %v", tt.encoding, err)
+			}
+			if !onlyChars(shortID, tt.allowed) {
+				t.Fatalf("%s %q generated %q, want only %q", tt.encoding, tt.idCase, shortID, tt.allowed)
+			}
+			if got, err := s.Load(context.Background(), shortID); err != nil || got.LongURL != link.LongURL {
+				t.Errorf("%s: Load(%q) = %q, %v; want %q", tt.encoding, shortID, got.LongURL, err, link.LongURL)
+			}
+		}
+	}
+}
+
+func TestWordAliases(t *testing.T) {
+	pattern := regexp.MustCompile(`^[a-z]+-[a-z]+-[a-z]+$`)
+	blacklisted := aliasWords[0][0]
+
+	db := newFakeDB()
+	s := newTestStorage(db)
+	s.idStrategy = IDStrategyWords
+	s.SetIDBlacklist([]string{blacklisted})
+
+	const links = 1000
+	for i := 0; i < links; i++ {
+		shortID, err := s.Save(context.Background(), Link{LongURL: "https://example.com/"})
+		if err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if !pattern.MatchString(shortID) {
+			t.Fatalf("alias %q does not match %s", shortID, pattern)
+		}
+		for list, word := range strings.Split(shortID, "-") {
+			if !slices.Contains(aliasWords[list], word) {
+				t.Errorf("alias %q: %q is not in word list %d", shortID, word, list)
+			}
+		}
+		if strings.Contains(shortID, blacklisted) {
+			t.Errorf("alias %q contains blacklisted %q", shortID, blacklisted)
+		}
+	}
+	// The retry loop resolves the occasional repeated alias, so every link gets its own
+	if len(db.urls) != links {
+		t.Errorf("stored %d distinct aliases, want %d", len(db.urls), links)
+	}
+
+	// Aliases end in a word, not a check character, and every alias passes the checksum
+	if !s.HasValidChecksum("happy-blue-tiger") {
+		t.Error("HasValidChecksum rejected a word alias")
+	}
+	if _, err := NewStorage("", Config{IDStrategy: IDStrategyWords, ShortIDChecksum: true}); err == nil {
+		t.Error("NewStorage accepted checksums with word aliases, want an error")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	s := newTestStorage(nil)
+	s.checksum = true
+	body := "abc123"
+	valid := body + string(checksumChar([]byte(body), s.charset))
+
+	tests := []struct {
+		name    string
+		shortID string
+		want    bool
+	}{
+		{"valid", valid, true},
+		{"typo in body", "abd123" + valid[len(valid)-1:], false},
+		{"transposed", "bac123" + valid[len(valid)-1:], false},
+		{"wrong check character", body + "!", false},
+		{"too short", "a", false},
+		{"outside charset", "ab-123" + valid[len(valid)-1:], false},
+	}
+	for _, tt := range tests {
+		if got := s.HasValidChecksum(tt.shortID); got != tt.want {
+			t.Errorf("%s: HasValidChecksum(%q) = %v, want %v", tt.name, tt.shortID, got, tt.want)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		if id := s.generateShortID(DefaultShortIDLength); !s.HasValidChecksum(id) {
+			t.Fatalf("generated ID %q fails its own checksum", id)
+		}
+	}
+
+	s.checksum = false
+	if !s.HasValidChecksum("anything") {
+		t.Error("HasValidChecksum with checksums disabled = false, want true")
+	}
+}
+
+func BenchmarkGenerateShortID(b *testing.B) {
+	s := newTestStorage(nil)
+	for i := 0; i < b.N; i++ {
+		s.generateShortID(DefaultShortIDLength)
+	}
+}
+
+// BenchmarkSave measures Save in a keyspace of 4^6 IDs pre-seeded to a given fill, so
+// random IDs collide at that rate. Each saved link is removed again to hold the fill steady.
+func BenchmarkSave(b *testing.B) {
+	const charset = "abcd"
+	for _, bc := range []struct {
+		name string
+		fill float64
+	}{
+		{"empty", 0},
+		{"quarter full", 0.25},
+		{"half full", 0.5},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			db := newFakeDB()
+			s := newTestStorage(db)
+			s.charset = charset
+			seed := rand.New(rand.NewSource(2))
+			id := make([]byte, DefaultShortIDLength)
+			for n := 0; n < int(math.Pow(float64(len(charset)), DefaultShortIDLength)); n++ {
+				if seed.Float64() >= bc.fill {
+					continue
+				}
+				for i, rest := len(id)-1, n; i >= 0; i, rest = i-1, rest/len(charset) {
+					id[i] = charset[rest%len(charset)]
+				}
+				db.urls[string(id)] = Link{ShortID: string(id)}
+			}
+
+			ctx := context.Background()
+			link := Link{LongURL: "https://example.com/benchmark"}
+			exhausted := 0
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				shortID, err := s.Save(ctx, link)
+				switch {
+				case errors.Is(err, ErrShortIDExhausted):
+					exhausted++
+				case err != nil:
+					b.Fatal(err)
+				default:
+					delete(db.urls, shortID)
+				}
+			}
+			b.ReportMetric(float64(exhausted)/float64(b.N), "exhausted/op")
+		})
+	}
+}
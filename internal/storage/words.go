@@ -0,0 +1,51 @@
+package storage
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+)
+
+// Supported short ID strategies.
+const (
+	IDStrategyRandom = "random"
+	IDStrategyWords  = "words"
+)
+
+var (
+	//go:embed wordlists/adjectives.txt
+	adjectiveList string
+	//go:embed wordlists/colors.txt
+	colorList string
+	//go:embed wordlists/animals.txt
+	animalList string
+)
+
+// aliasWords holds the word lists a word alias draws from, one word per list in order,
+// giving aliases such as "happy-blue-tiger".
+var aliasWords = [][]string{
+	strings.Fields(adjectiveList),
+	strings.Fields(colorList),
+	strings.Fields(animalList),
+}
+
+// validIDStrategy returns the effective strategy for strategy, where empty means random.
+func validIDStrategy(strategy string) (string, error) {
+	switch strategy {
+	case "", IDStrategyRandom:
+		return IDStrategyRandom, nil
+	case IDStrategyWords:
+		return IDStrategyWords, nil
+	default:
+		return "", fmt.Errorf("invalid ID strategy %q: must be %s or %s", strategy, IDStrategyRandom, IDStrategyWords)
+	}
+}
+
+// generateWordAlias returns a random alias of one word from each list, joined by dashes.
+func (s *Storage) generateWordAlias() string {
+	words := make([]string, len(aliasWords))
+	for i, list := range aliasWords {
+		words[i] = list[s.r.Intn(len(list))]
+	}
+	return strings.Join(words, "-")
+}
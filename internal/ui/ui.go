@@ -0,0 +1,21 @@
+package ui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+// Handler serves the embedded single-page UI. It is meant to be mounted under a
+// prefix such as "/app/" with http.StripPrefix.
+func Handler() http.Handler {
+	files, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		// The embedded directory is fixed at build time
+		panic(err)
+	}
+	return http.FileServer(http.FS(files))
+}
@@ -2,24 +2,35 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/inirafli/go-url-shortener/internal/handler"
+	"github.com/inirafli/go-url-shortener/internal/middleware"
 	"github.com/inirafli/go-url-shortener/internal/storage"
+	"github.com/inirafli/go-url-shortener/internal/ui"
 	"github.com/joho/godotenv"
 )
 
 func main() {
-	// Load environment variables
-	err := godotenv.Load()
-	if err != nil {
-		log.Printf("Warning: Could not load .env file: %v", err)
+	selfTest := flag.Bool("selftest", false, "shorten and resolve a known URL against the configured storage, then exit")
+	flag.Parse()
+
+	// Load environment variables; ENV_FILE and REQUIRE_ENV_FILE come from the process environment
+	envFile := getEnv("ENV_FILE", ".env")
+	processEnv := envKeys()
+	if err := loadEnvFile(envFile, getEnvBool("REQUIRE_ENV_FILE", false)); err != nil {
+		log.Fatal(err)
 	}
 
 	// Load configuration from env
@@ -30,56 +41,207 @@ func main() {
 	dbName := getEnv("DB_NAME", "url_shortener_db")
 	dbSSLMode := getEnv("DB_SSLMODE", "disable")
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		dbHost, dbPort, dbUser, dbPassword, dbName, dbSSLMode)
+	dsn := buildDSN(dbHost, dbPort, dbUser, dbPassword, dbName, dbSSLMode)
 
 	log.Printf("Attempting to connect to database: %s:%s/%s", dbHost, dbPort, dbName)
 
+	storageCfg := storage.Config{
+		ShortIDCase:      getEnv("SHORT_ID_CASE", "mixed"),
+		IDEncoding:       getEnv("ID_ENCODING", storage.EncodingBase62),
+		IDStrategy:       getEnv("ID_STRATEGY", storage.IDStrategyRandom),
+		StatementTimeout: getEnvDuration("DB_STATEMENT_TIMEOUT", 0),
+		MaxTotalLinks:    getEnvInt("MAX_TOTAL_LINKS", 0),
+		ShortIDChecksum:  getEnvBool("SHORT_ID_CHECKSUM", false),
+		PingTimeout:      getEnvDuration("DB_PING_TIMEOUT", 5*time.Second),
+		MaxFillRatio:     getEnvFloat("SHORT_ID_MAX_FILL_RATIO", 0),
+		IDBlacklist:      getEnvList("ID_BLACKLIST"),
+		Driver:           getEnv("DB_DRIVER", storage.DriverStdlib),
+		IDCooldownDays:   int(getEnvInt("ID_COOLDOWN_DAYS", 0)),
+		ReplicaDSN:       getEnv("DB_REPLICA_DSN", ""),
+	}
+
 	// Initialize storage
-	urlStorage, err := storage.NewStorage(dsn)
+	urlStorage, err := storage.NewStorage(dsn, storageCfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
 
-	urlHandler := handler.NewHandler(urlStorage)
+	if *selfTest {
+		err := runSelfTest(urlStorage)
+		urlStorage.Close()
+		if err != nil {
+			log.Fatalf("Self-test failed: %v", err)
+		}
+		return
+	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/shorten", urlHandler.ShortenURL)
+	reloadable := loadReloadableConfig()
+	handlerCfg := handler.Config{
+		StripParams:           reloadable.StripParams,
+		AllowGetShorten:       getEnvBool("ALLOW_GET_SHORTEN", false),
+		AdminToken:            getEnv("ADMIN_TOKEN", ""),
+		NormalizeShortIDs:     getEnvBool("NORMALIZE_SHORT_IDS", true),
+		VanityDomains:         reloadable.VanityDomains,
+		KnownShorteners:       reloadable.KnownShorteners,
+		ExpandLinks:           getEnvBool("EXPAND_LINKS", false),
+		MaxRedirectDepth:      int(getEnvInt("MAX_REDIRECT_DEPTH", 10)),
+		MinShortIDLength:      int(getEnvInt("MIN_SHORT_ID_LENGTH", 3)),
+		StoreCreatorMeta:      getEnvBool("STORE_CREATOR_META", false),
+		ExposeErrorDetails:    getEnv("ENV", "prod") == "dev",
+		RobotsTxt:             reloadable.RobotsTxt,
+		APINaming:             getEnv("API_NAMING", handler.NamingSnakeCase),
+		RequestEncodings:      getEnvList("REQUEST_ENCODINGS"),
+		DedupWindow:           getEnvDuration("SHORTEN_DEDUP_WINDOW", 0),
+		FormResultRedirect:    getEnvBool("FORM_RESULT_REDIRECT", false),
+		BaseURL:               getEnv("BASE_URL", ""),
+		EnforceCanonicalHost:  getEnvBool("ENFORCE_CANONICAL_HOST", false),
+		ShortURLTemplate:      getEnv("SHORT_URL_TEMPLATE", handler.DefaultShortURLTemplate),
+		AssumeScheme:          strings.ToLower(getEnv("ASSUME_SCHEME", "")),
+		CheckLinks:            getEnvBool("LINK_HEALTHCHECK", false),
+		DetailedAnalytics:     getEnvBool("DETAILED_ANALYTICS", false),
+		RecordReferrer:        getEnvBool("RECORD_REFERRER", false),
+		DefaultHost:           getEnv("DEFAULT_HOST", ""),
+		URLCredentials:        strings.ToLower(getEnv("HANDLE_URL_CREDENTIALS", handler.CredentialsReject)),
+		PreviewSecret:         getEnv("PREVIEW_TOKEN_SECRET", ""),
+		PreviewTokenTTL:       getEnvDuration("PREVIEW_TOKEN_TTL", 5*time.Minute),
+		MaintenanceMode:       getEnvBool("MAINTENANCE_MODE", false),
+		MaintenanceRetryAfter: getEnvDuration("MAINTENANCE_RETRY_AFTER", 5*time.Minute),
+		AccessDebounceWindow:  getEnvDuration("ACCESS_DEBOUNCE_WINDOW", 0),
+		AllowDataURLs:         getEnvBool("ALLOW_DATA_URLS", false),
+		MaxDataURLBytes:       int(getEnvInt("DATA_URL_MAX_BYTES", 2048)),
+		AllowedPorts:          getEnvList("ALLOWED_PORTS"),
+		ChecksumLegacyLookup:  getEnvBool("SHORT_ID_CHECKSUM_LEGACY_LOOKUP", false),
+	}
+	if handlerCfg.APINaming != handler.NamingSnakeCase && handlerCfg.APINaming != handler.NamingCamelCase {
+		log.Fatalf("Invalid API_NAMING %q: must be %s or %s", handlerCfg.APINaming, handler.NamingSnakeCase, handler.NamingCamelCase)
+	}
 
-	// Handler for the root path "/" and any other paths.
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		urlHandler.ShortenURL(w, r.WithContext(r.Context()))
-	})
+	if handlerCfg.BaseURL != "" {
+		if _, err := handler.ParseBaseURL(handlerCfg.BaseURL); err != nil {
+			log.Fatalf("Invalid BASE_URL: %v", err)
+		}
+	} else if handlerCfg.EnforceCanonicalHost {
+		log.Fatalf("ENFORCE_CANONICAL_HOST requires BASE_URL to be set")
+	}
+
+	if handlerCfg.AssumeScheme != "" && handlerCfg.AssumeScheme != "http" && handlerCfg.AssumeScheme != "https" {
+		log.Fatalf("Invalid ASSUME_SCHEME %q: must be http or https", handlerCfg.AssumeScheme)
+	}
+
+	if handlerCfg.URLCredentials != handler.CredentialsReject && handlerCfg.URLCredentials != handler.CredentialsStrip {
+		log.Fatalf("Invalid HANDLE_URL_CREDENTIALS %q: must be %s or %s", handlerCfg.URLCredentials, handler.CredentialsReject, handler.CredentialsStrip)
+	}
+
+	for _, port := range handlerCfg.AllowedPorts {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 || strconv.Itoa(n) != port {
+			log.Fatalf("Invalid ALLOWED_PORTS entry %q: must be a port number between 1 and 65535", port)
+		}
+	}
 
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/" {
-			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			fmt.Fprintln(w, "Welcome to the Go URL Shortener! (with PostgreSQL)")
-			fmt.Fprintln(w, "\nUsage:")
-			fmt.Fprintln(w, "  POST /shorten   - with JSON body {\"long_url\": \"...\"}")
-			fmt.Fprintln(w, "  GET /{shortID} - redirects to the original URL")
-			return
+	// A custom page for browsers hitting server errors; the built-in page is used when unset
+	if path := getEnv("ERROR_PAGE_FILE", ""); path != "" {
+		page, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read ERROR_PAGE_FILE: %v", err)
+		}
+		if _, err := handler.ParseErrorPage(string(page)); err != nil {
+			log.Fatalf("Invalid ERROR_PAGE_FILE: %v", err)
 		}
+		handlerCfg.ErrorPage = string(page)
+	}
 
-		urlHandler.RedirectURL(w, r.WithContext(r.Context()))
-	})
+	shortIDPrefix, err := handler.ShortIDPathPrefix(handlerCfg.ShortURLTemplate)
+	if err != nil {
+		log.Fatalf("Invalid SHORT_URL_TEMPLATE: %v", err)
+	}
+
+	urlHandler := handler.NewHandler(urlStorage, handlerCfg)
+
+	// Periodically log connection pool statistics until shutdown
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	defer stopMonitor()
+	if interval := getEnvDuration("POOL_STATS_INTERVAL", time.Minute); interval > 0 {
+		go urlStorage.MonitorPool(monitorCtx, interval)
+	}
+
+	enableUI := getEnvBool("ENABLE_UI", false)
+	mux := newMux(urlHandler, handlerCfg, enableUI, shortIDPrefix)
+
+	// Security headers are on by default; SECURITY_HEADERS replaces the set, empty disables them
+	securityHeaders := middleware.DefaultSecurityHeaders
+	if value, ok := os.LookupEnv("SECURITY_HEADERS"); ok {
+		securityHeaders, err = middleware.ParseHeaders(value)
+		if err != nil {
+			log.Fatalf("Invalid SECURITY_HEADERS: %v", err)
+		}
+	}
+
+	var rootHandler http.Handler = middleware.Recover(urlHandler.ServerError, mux)
+	if len(securityHeaders) > 0 {
+		rootHandler = middleware.SecurityHeaders(securityHeaders, rootHandler)
+	}
+
+	// Correlation IDs are assigned first so every response carries one
+	rootHandler = middleware.RequestID(rootHandler)
+
+	// Track in-flight requests so shutdown can report what it is draining
+	inFlight := &middleware.InFlight{}
+	rootHandler = inFlight.Handler(rootHandler)
 
 	port := getEnv("PORT", "8080")
+	// TLS is served directly when both a certificate and a key are configured
+	certFile := getEnv("TLS_CERT_FILE", "")
+	keyFile := getEnv("TLS_KEY_FILE", "")
+	if (certFile == "") != (keyFile == "") {
+		log.Fatalf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	var tlsConfig *tls.Config
+	if certFile != "" {
+		tlsConfig, err = newTLSConfig(getEnv("TLS_MIN_VERSION", "1.2"))
+		if err != nil {
+			log.Fatalf("Invalid TLS_MIN_VERSION: %v", err)
+		}
+	}
+
 	server := &http.Server{
 		Addr:         ":" + port,
-		Handler:      mux,
+		Handler:      rootHandler,
+		TLSConfig:    tlsConfig,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
 
+	shutdownTimeout := getShutdownTimeout()
+
 	// Channel to listen for OS signals
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP re-reads the env file and applies the settings that can change without a restart
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
 	go func() {
-		log.Printf("Starting URL Shortener server on port %s", port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		for range reloadChan {
+			log.Println("Received SIGHUP, reloading configuration...")
+			if err := reloadEnvFile(envFile, processEnv); err != nil {
+				log.Printf("Warning: Could not reload %s file: %v", envFile, err)
+			}
+			urlHandler.Reload(loadReloadableConfig())
+			urlStorage.SetIDBlacklist(getEnvList("ID_BLACKLIST"))
+		}
+	}()
+
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			log.Printf("Starting URL Shortener server with TLS on port %s", port)
+			err = server.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			log.Printf("Starting URL Shortener server on port %s", port)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Could not listen on %s: %v\n", port, err)
 		}
 	}()
@@ -89,14 +251,18 @@ func main() {
 	log.Println("Shutting down server...")
 
 	// Create a deadline context for shutdown
-	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 15*time.Second)
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancelShutdown()
 
+	inFlight.StartDraining()
+
 	// Attempt shutdown
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("Server shutdown failed: %v", err)
 	}
 
+	stopMonitor()
+
 	// Close the database connection
 	if err := urlStorage.Close(); err != nil {
 		log.Printf("Error closing database connection pool: %v", err)
@@ -105,6 +271,179 @@ func main() {
 	log.Println("Server stopped")
 }
 
+// loadReloadableConfig reads the handler settings that SIGHUP reloads. Everything else,
+// such as the database DSN or the listen address, needs a restart to change.
+func loadReloadableConfig() handler.ReloadableConfig {
+	return handler.ReloadableConfig{
+		StripParams:     getEnvList("STRIP_PARAMS"),
+		VanityDomains:   getEnvList("VANITY_DOMAINS"),
+		KnownShorteners: getEnvList("KNOWN_SHORTENERS"),
+		// Literal "\n" sequences allow a multi-line robots.txt policy in a single variable
+		RobotsTxt: strings.ReplaceAll(getEnv("ROBOTS_TXT", `User-agent: *\nDisallow: /\n`), `\n`, "\n"),
+	}
+}
+
+// newMux registers every endpoint of the service on a new ServeMux.
+func newMux(urlHandler *handler.Handler, handlerCfg handler.Config, enableUI bool, shortIDPrefix string) *http.ServeMux {
+	mux := http.NewServeMux()
+	// Endpoints that create, change or delete links are disabled in maintenance mode
+	writable := urlHandler.RequireWritable
+	shortenMethods := map[string]http.HandlerFunc{http.MethodPost: writable(urlHandler.ShortenURL)}
+	if handlerCfg.AllowGetShorten {
+		shortenMethods[http.MethodGet] = writable(urlHandler.ShortenURL)
+		// A HEAD request would create a link without returning it
+		shortenMethods[http.MethodHead] = nil
+	}
+	route(mux, urlHandler, "/shorten", shortenMethods)
+	if handlerCfg.FormResultRedirect {
+		route(mux, urlHandler, "/shorten/result", map[string]http.HandlerFunc{http.MethodGet: urlHandler.ShortenResult})
+	}
+	route(mux, urlHandler, "/api/urls", map[string]http.HandlerFunc{http.MethodDelete: urlHandler.RequireAdmin(writable(urlHandler.DeleteURLs))})
+	route(mux, urlHandler, "/api/urls/{shortID}/rotate", map[string]http.HandlerFunc{http.MethodPost: urlHandler.RequireAdmin(writable(urlHandler.RotateURL))})
+	route(mux, urlHandler, "/api/urls/healthcheck", map[string]http.HandlerFunc{http.MethodPost: urlHandler.RequireAdmin(urlHandler.CheckLinks)})
+	route(mux, urlHandler, "/api/batch", map[string]http.HandlerFunc{http.MethodPost: urlHandler.RequireAdmin(writable(urlHandler.BatchOperations))})
+	route(mux, urlHandler, "/api/urls/search", map[string]http.HandlerFunc{http.MethodGet: urlHandler.RequireAdmin(urlHandler.SearchURLs)})
+	route(mux, urlHandler, "/api/stats/created", map[string]http.HandlerFunc{http.MethodGet: urlHandler.RequireAdmin(urlHandler.CreatedCount)})
+	route(mux, urlHandler, "/api/stats/summary", map[string]http.HandlerFunc{http.MethodGet: urlHandler.RequireAdmin(urlHandler.Summary)})
+	route(mux, urlHandler, "/stats/{shortID}/accesses", map[string]http.HandlerFunc{http.MethodGet: urlHandler.RequireAdmin(urlHandler.Accesses)})
+	route(mux, urlHandler, "/stats/{shortID}/referrers", map[string]http.HandlerFunc{http.MethodGet: urlHandler.RequireAdmin(urlHandler.Referrers)})
+	route(mux, urlHandler, "/api/expand/{shortID}", map[string]http.HandlerFunc{http.MethodGet: urlHandler.ExpandURL})
+	route(mux, urlHandler, "/api/preview-token/{shortID}", map[string]http.HandlerFunc{http.MethodPost: urlHandler.RequireAdmin(urlHandler.CreatePreviewToken)})
+	route(mux, urlHandler, "/preview", map[string]http.HandlerFunc{http.MethodGet: urlHandler.Preview})
+	route(mux, urlHandler, "/api/validate", map[string]http.HandlerFunc{http.MethodPost: urlHandler.ValidateURL})
+	route(mux, urlHandler, "/api/maintenance", map[string]http.HandlerFunc{
+		http.MethodGet: urlHandler.RequireAdmin(urlHandler.Maintenance),
+		http.MethodPut: urlHandler.RequireAdmin(urlHandler.Maintenance),
+	})
+	route(mux, urlHandler, "/api/config", map[string]http.HandlerFunc{http.MethodGet: urlHandler.RequireAdmin(urlHandler.ServerConfig)})
+	route(mux, urlHandler, "/healthz", map[string]http.HandlerFunc{http.MethodGet: urlHandler.Health})
+	route(mux, urlHandler, "/robots.txt", map[string]http.HandlerFunc{http.MethodGet: urlHandler.RobotsTxt})
+
+	if enableUI {
+		mux.Handle("GET /app/", http.StripPrefix("/app", ui.Handler()))
+		mux.Handle("GET /app", http.RedirectHandler("/app/", http.StatusMovedPermanently))
+	}
+
+	route(mux, urlHandler, "/{$}", map[string]http.HandlerFunc{http.MethodGet: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "Welcome to the Go URL Shortener! (with PostgreSQL)")
+		fmt.Fprintln(w, "\nUsage:")
+		fmt.Fprintln(w, "  POST /shorten   - with JSON body {\"long_url\": \"...\"}")
+		if handlerCfg.AllowGetShorten {
+			fmt.Fprintln(w, "  GET /shorten?url=... - shortens the given URL")
+		}
+		fmt.Fprintln(w, "  GET /{shortID} - redirects to the original URL")
+		if enableUI {
+			fmt.Fprintln(w, "  GET /app        - web UI for shortening URLs")
+		}
+	}})
+
+	// Every other path is a short ID. Any method is routed: links with a 307 or 308
+	// status redirect non-GET requests too. The remainder wildcard keeps trailing
+	// slashes for short ID normalization.
+	mux.HandleFunc("/{shortID...}", urlHandler.RedirectURL)
+	if shortIDPrefix != "/" {
+		// Links rendered by a custom SHORT_URL_TEMPLATE; the root keeps serving older links
+		mux.HandleFunc(shortIDPrefix+"{shortID...}", urlHandler.RedirectURL)
+	}
+
+	return mux
+}
+
+// route registers a handler per method for pattern and answers any other method with a 405.
+// GET handlers also serve HEAD requests, unless handlers maps HEAD to nil to opt out of it.
+func route(mux *http.ServeMux, urlHandler *handler.Handler, pattern string, handlers map[string]http.HandlerFunc) {
+	methods := make([]string, 0, len(handlers))
+	for method, h := range handlers {
+		if h == nil {
+			continue
+		}
+		methods = append(methods, method)
+		if method == http.MethodGet {
+			if _, ok := handlers[http.MethodHead]; !ok {
+				methods = append(methods, http.MethodHead)
+			}
+		}
+	}
+	sort.Strings(methods)
+	notAllowed := urlHandler.MethodNotAllowed(methods...)
+	for method, h := range handlers {
+		if h == nil {
+			h = notAllowed
+		}
+		mux.HandleFunc(method+" "+pattern, h)
+	}
+	mux.HandleFunc(pattern, notAllowed)
+}
+
+// buildDSN assembles the PostgreSQL connection string. An empty password is left out
+// entirely so libpq-style fallbacks (PGPASSWORD, .pgpass, trust auth) still apply.
+func buildDSN(host, port, user, password, dbName, sslMode string) string {
+	dsn := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=%s", host, port, user, dbName, sslMode)
+	if password == "" {
+		log.Println("Warning: DB_PASSWORD is empty, connecting without a password")
+		return dsn
+	}
+	return dsn + " password=" + password
+}
+
+// tlsVersions maps the accepted TLS_MIN_VERSION values to their tls constants.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig returns the TLS settings of the HTTPS listener for a minimum version such as "1.2".
+func newTLSConfig(minVersion string) (*tls.Config, error) {
+	version, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(minVersion), "tls")]
+	if !ok {
+		return nil, fmt.Errorf("unsupported version %q: must be 1.0, 1.1, 1.2 or 1.3", minVersion)
+	}
+	return &tls.Config{MinVersion: version}, nil
+}
+
+// loadEnvFile loads envFile without overriding the process environment. A missing or
+// unreadable file is only a warning unless required is set.
+func loadEnvFile(envFile string, required bool) error {
+	if err := godotenv.Load(envFile); err != nil {
+		if required {
+			return fmt.Errorf("could not load required env file %s: %w", envFile, err)
+		}
+		log.Printf("Warning: Could not load %s file: %v", envFile, err)
+	}
+	return nil
+}
+
+// envKeys returns the names of the variables currently set in the process environment.
+func envKeys() map[string]bool {
+	keys := make(map[string]bool)
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		keys[key] = true
+	}
+	return keys
+}
+
+// reloadEnvFile re-applies envFile on SIGHUP. Like the initial godotenv.Load, it never
+// overrides the variables in keep, which were set by the process environment at startup.
+func reloadEnvFile(envFile string, keep map[string]bool) error {
+	values, err := godotenv.Read(envFile)
+	if err != nil {
+		return err
+	}
+	for key, value := range values {
+		if keep[key] {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
 		return value
@@ -112,3 +451,109 @@ func getEnv(key, fallback string) string {
 	log.Printf("Environment variable %s not set, using default: %s", key, fallback)
 	return fallback
 }
+
+// getEnvBool reads a boolean environment variable, falling back on unset or invalid values.
+func getEnvBool(key string, fallback bool) bool {
+	value := getEnv(key, strconv.FormatBool(fallback))
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean for %s: %q, using default: %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+// getEnvInt reads an integer environment variable, falling back on unset or invalid values.
+func getEnvInt(key string, fallback int64) int64 {
+	value := getEnv(key, strconv.FormatInt(fallback, 10))
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Printf("Invalid integer for %s: %q, using default: %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// getEnvFloat reads a floating-point environment variable, falling back on unset or invalid values.
+func getEnvFloat(key string, fallback float64) float64 {
+	value := getEnv(key, strconv.FormatFloat(fallback, 'g', -1, 64))
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid number for %s: %q, using default: %g", key, value, fallback)
+		return fallback
+	}
+	return f
+}
+
+// getEnvDuration reads a duration environment variable (e.g. "500ms", "5s"), falling back on unset or invalid values.
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := getEnv(key, fallback.String())
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s: %q, using default: %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// defaultShutdownTimeout bounds graceful shutdown when SHUTDOWN_TIMEOUT is unset or invalid.
+const defaultShutdownTimeout = 15 * time.Second
+
+// getShutdownTimeout reads SHUTDOWN_TIMEOUT, falling back on unset, invalid or non-positive values.
+func getShutdownTimeout() time.Duration {
+	timeout := getEnvDuration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+	if timeout <= 0 {
+		log.Printf("Invalid SHUTDOWN_TIMEOUT %s, using default: %s", timeout, defaultShutdownTimeout)
+		return defaultShutdownTimeout
+	}
+	return timeout
+}
+
+// getEnvList reads a comma-separated environment variable, dropping empty items.
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// selfTestStore is the part of the storage the self-test exercises.
+type selfTestStore interface {
+	Save(ctx context.Context, link storage.Link) (string, error)
+	Load(ctx context.Context, shortID string) (storage.Link, error)
+	Delete(ctx context.Context, shortID string) error
+}
+
+// runSelfTest shortens a known URL, resolves it again and verifies the round trip.
+func runSelfTest(s selfTestStore) error {
+	const testURL = "https://example.com/selftest"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	shortID, err := s.Save(ctx, storage.Link{LongURL: testURL})
+	if err != nil {
+		return fmt.Errorf("save failed: %w", err)
+	}
+	// Leave no test link behind, whether or not the checks below pass
+	defer func() {
+		if err := s.Delete(ctx, shortID); err != nil {
+			log.Printf("Self-test could not delete short ID '%s': %v", shortID, err)
+		}
+	}()
+
+	link, err := s.Load(ctx, shortID)
+	if err != nil {
+		return fmt.Errorf("load of short ID '%s' failed: %w", shortID, err)
+	}
+
+	if link.LongURL != testURL {
+		return fmt.Errorf("round trip mismatch for short ID '%s': got %q, want %q", shortID, link.LongURL, testURL)
+	}
+
+	fmt.Printf("Self-test passed: %s -> %s\n", shortID, link.LongURL)
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inirafli/go-url-shortener/internal/handler"
+	"github.com/inirafli/go-url-shortener/internal/storage"
+	"github.com/joho/godotenv"
+)
+
+func TestBuildDSN(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"secret", "host=db port=5432 user=app dbname=urls sslmode=disable password=secret"},
+		{"", "host=db port=5432 user=app dbname=urls sslmode=disable"},
+	}
+	for _, tt := range tests {
+		if got := buildDSN("db", "5432", "app", tt.password, "urls", "disable"); got != tt.want {
+			t.Errorf("buildDSN with password %q = %q, want %q", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint16
+		wantErr bool
+	}{
+		{"1.2", tls.VersionTLS12, false},
+		{"1.3", tls.VersionTLS13, false},
+		{"1.0", tls.VersionTLS10, false},
+		{"TLS1.3", tls.VersionTLS13, false},
+		{"tls1.1", tls.VersionTLS11, false},
+		{"1.4", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		cfg, err := newTLSConfig(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("newTLSConfig(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.MinVersion != tt.want {
+			t.Errorf("newTLSConfig(%q).MinVersion = %#x, want %#x", tt.in, cfg.MinVersion, tt.want)
+		}
+	}
+}
+
+func TestRouteMethodNotAllowed(t *testing.T) {
+	tests := []struct {
+		cfg       handler.Config
+		method    string
+		path      string
+		wantAllow string
+	}{
+		{handler.Config{}, http.MethodGet, "/shorten", "POST"},
+		{handler.Config{}, http.MethodPut, "/shorten", "POST"},
+		{handler.Config{AllowGetShorten: true}, http.MethodPut, "/shorten", "GET, POST"},
+		{handler.Config{AllowGetShorten: true}, http.MethodHead, "/shorten", "GET, POST"},
+		{handler.Config{FormResultRedirect: true}, http.MethodPost, "/shorten/result", "GET, HEAD"},
+		{handler.Config{}, http.MethodGet, "/api/urls", "DELETE"},
+		{handler.Config{}, http.MethodGet, "/api/urls/abc/rotate", "POST"},
+		{handler.Config{}, http.MethodGet, "/api/urls/healthcheck", "POST"},
+		{handler.Config{}, http.MethodGet, "/api/batch", "POST"},
+		{handler.Config{}, http.MethodPost, "/api/urls/search", "GET, HEAD"},
+		{handler.Config{}, http.MethodPost, "/api/stats/created", "GET, HEAD"},
+		{handler.Config{}, http.MethodPost, "/api/stats/summary", "GET, HEAD"},
+		{handler.Config{}, http.MethodDelete, "/stats/abc/accesses", "GET, HEAD"},
+		{handler.Config{}, http.MethodDelete, "/stats/abc/referrers", "GET, HEAD"},
+		{handler.Config{}, http.MethodPost, "/api/expand/abc", "GET, HEAD"},
+		{handler.Config{}, http.MethodGet, "/api/preview-token/abc", "POST"},
+		{handler.Config{}, http.MethodPost, "/preview", "GET, HEAD"},
+		{handler.Config{}, http.MethodGet, "/api/validate", "POST"},
+		{handler.Config{}, http.MethodPost, "/api/maintenance", "GET, HEAD, PUT"},
+		{handler.Config{}, http.MethodPost, "/api/config", "GET, HEAD"},
+		{handler.Config{}, http.MethodPost, "/healthz", "GET, HEAD"},
+		{handler.Config{}, http.MethodPost, "/robots.txt", "GET, HEAD"},
+		{handler.Config{}, http.MethodPost, "/", "GET, HEAD"},
+	}
+
+	for _, tt := range tests {
+		mux := newMux(handler.NewHandler(nil, tt.cfg), tt.cfg, false, "/")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, w.Code, http.StatusMethodNotAllowed)
+		}
+		if got := w.Header().Get("Allow"); got != tt.wantAllow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.path, got, tt.wantAllow)
+		}
+	}
+}
+
+func TestReloadEnvFile(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envFile, []byte("RELOAD_TEST_PROCESS=from-file\nRELOAD_TEST_FILE=v1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RELOAD_TEST_PROCESS", "from-process")
+	t.Setenv("RELOAD_TEST_FILE", "")
+	os.Unsetenv("RELOAD_TEST_FILE")
+	processEnv := envKeys()
+	if err := godotenv.Load(envFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(envFile, []byte("RELOAD_TEST_PROCESS=changed\nRELOAD_TEST_FILE=v2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadEnvFile(envFile, processEnv); err != nil {
+		t.Fatalf("reloadEnvFile: %v", err)
+	}
+	if got := os.Getenv("RELOAD_TEST_PROCESS"); got != "from-process" {
+		t.Errorf("RELOAD_TEST_PROCESS = %q, want the process value %q", got, "from-process")
+	}
+	if got := os.Getenv("RELOAD_TEST_FILE"); got != "v2" {
+		t.Errorf("RELOAD_TEST_FILE = %q, want the reloaded value %q", got, "v2")
+	}
+
+	if err := reloadEnvFile(filepath.Join(t.TempDir(), "missing"), processEnv); err == nil {
+		t.Error("reloadEnvFile of a missing file succeeded, want an error")
+	}
+}
+
+func TestGetShutdownTimeout(t *testing.T) {
+	tests := []struct {
+		value string
+		set   bool
+		want  time.Duration
+	}{
+		{"", false, defaultShutdownTimeout},
+		{"", true, defaultShutdownTimeout},
+		{"30s", true, 30 * time.Second},
+		{"1m30s", true, 90 * time.Second},
+		{"500ms", true, 500 * time.Millisecond},
+		{"30", true, defaultShutdownTimeout},
+		{"soon", true, defaultShutdownTimeout},
+		{"0s", true, defaultShutdownTimeout},
+		{"-5s", true, defaultShutdownTimeout},
+	}
+	for _, tt := range tests {
+		t.Setenv("SHUTDOWN_TIMEOUT", tt.value)
+		if !tt.set {
+			os.Unsetenv("SHUTDOWN_TIMEOUT")
+		}
+		if got := getShutdownTimeout(); got != tt.want {
+			t.Errorf("SHUTDOWN_TIMEOUT %q (set %t): got %s, want %s", tt.value, tt.set, got, tt.want)
+		}
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	present := filepath.Join(dir, ".env")
+	if err := os.WriteFile(present, []byte("LOAD_ENV_TEST=loaded\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.env")
+
+	tests := []struct {
+		name      string
+		envFile   string
+		required  bool
+		wantErr   bool
+		wantValue string
+	}{
+		{"missing optional", missing, false, false, ""},
+		{"missing required", missing, true, true, ""},
+		{"present optional", present, false, false, "loaded"},
+		{"present required", present, true, false, "loaded"},
+	}
+	for _, tt := range tests {
+		t.Setenv("LOAD_ENV_TEST", "")
+		os.Unsetenv("LOAD_ENV_TEST")
+
+		err := loadEnvFile(tt.envFile, tt.required)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: loadEnvFile error = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+		if got := os.Getenv("LOAD_ENV_TEST"); got != tt.wantValue {
+			t.Errorf("%s: LOAD_ENV_TEST = %q, want %q", tt.name, got, tt.wantValue)
+		}
+	}
+}
+
+// selfTestFake is an in-memory selfTestStore whose Load can be made to misbehave.
+type selfTestFake struct {
+	links   map[string]storage.Link
+	saveErr error
+	loadErr error
+	// loadURL, when set, replaces the long URL Load returns
+	loadURL string
+}
+
+func (f *selfTestFake) Save(ctx context.Context, link storage.Link) (string, error) {
+	if f.saveErr != nil {
+		return "", f.saveErr
+	}
+	f.links["self1"] = link
+	return "self1", nil
+}
+
+func (f *selfTestFake) Load(ctx context.Context, shortID string) (storage.Link, error) {
+	link, ok := f.links[shortID]
+	switch {
+	case f.loadErr != nil:
+		return storage.Link{}, f.loadErr
+	case !ok:
+		return storage.Link{}, storage.ErrNotFound
+	case f.loadURL != "":
+		link.LongURL = f.loadURL
+	}
+	return link, nil
+}
+
+func (f *selfTestFake) Delete(ctx context.Context, shortID string) error {
+	delete(f.links, shortID)
+	return nil
+}
+
+func TestRunSelfTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   *selfTestFake
+		wantErr string
+	}{
+		{"pass", &selfTestFake{}, ""},
+		{"save fails", &selfTestFake{saveErr: errors.New("disk full")}, "save failed: disk full"},
+		{"load fails", &selfTestFake{loadErr: storage.ErrNotFound}, "load of short ID 'self1' failed"},
+		{"mismatch", &selfTestFake{loadURL: "https://example.com/other"}, "round trip mismatch"},
+	}
+	for _, tt := range tests {
+		tt.store.links = make(map[string]storage.Link)
+		err := runSelfTest(tt.store)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: runSelfTest error = %v, want none", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: runSelfTest error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		if len(tt.store.links) != 0 {
+			t.Errorf("%s: runSelfTest left %d links behind", tt.name, len(tt.store.links))
+		}
+	}
+}